@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/base64"
+	"math"
+	"regexp"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+const BADGE_LABEL = "ScoreMe"
+const BADGE_LABEL_COLOR = "#34495E"
+const BADGE_STYLE_LABEL_COLOR = "#555"
+
+// Longest label we'll draw, in characters
+const MAX_BADGE_LABEL_LENGTH = 40
+
+// Largest logo data URI we'll embed (32KB)
+const MAX_BADGE_LOGO_BYTES = 32 << 10
+
+// Size of the logo drawn before the label, and the gap after it
+const BADGE_LOGO_SIZE = 14
+const BADGE_LOGO_PADDING = 3
+
+// Shields-style badges, each rendered by templates/badges/<style>.svg
+var BADGE_STYLES = []string{"flat", "flat-square", "plastic", "for-the-badge"}
+
+// shields.io's named colors, so badges can be configured the same way
+var BADGE_COLORS = map[string]string{
+	"brightgreen":   "#4C1",
+	"green":         "#97CA00",
+	"yellowgreen":   "#A4A61D",
+	"yellow":        "#DFB317",
+	"orange":        "#FE7D37",
+	"red":           "#E05D44",
+	"blue":          "#007EC6",
+	"grey":          "#555",
+	"gray":          "#555",
+	"lightgrey":     "#9F9F9F",
+	"lightgray":     "#9F9F9F",
+	"success":       "#4C1",
+	"important":     "#FE7D37",
+	"critical":      "#E05D44",
+	"informational": "#007EC6",
+	"inactive":      "#9F9F9F",
+}
+
+// Built-in logos that can be used by name with the `logo` parameter
+var BADGE_LOGOS = map[string]string{
+	"book":     "data:image/svg+xml;base64,PHN2ZyB4bWxucz0iaHR0cDovL3d3dy53My5vcmcvMjAwMC9zdmciIHZpZXdCb3g9IjAgMCAxNiAxNiI+PHBhdGggZmlsbD0iI2ZmZiIgZD0iTTIgMi41QzIgMS43IDIuNyAxIDMuNSAxSDE0djEySDMuNWEuNS41IDAgMCAwIDAgMUgxNHYxSDMuNUExLjUgMS41IDAgMCAxIDIgMTMuNXpNNSA0djFoNlY0eiIvPjwvc3ZnPg==",
+	"document": "data:image/svg+xml;base64,PHN2ZyB4bWxucz0iaHR0cDovL3d3dy53My5vcmcvMjAwMC9zdmciIHZpZXdCb3g9IjAgMCAxNiAxNiI+PHBhdGggZmlsbD0iI2ZmZiIgZD0iTTMgMWg3bDMgM3YxMUgzem0yIDV2MWg2VjZ6bTAgM3YxaDZWOXptMCAzdjFoNHYtMXoiLz48L3N2Zz4=",
+	"markdown": "data:image/svg+xml;base64,PHN2ZyB4bWxucz0iaHR0cDovL3d3dy53My5vcmcvMjAwMC9zdmciIHZpZXdCb3g9IjAgMCAxNiAxNiI+PHBhdGggZmlsbD0iI2ZmZiIgZD0iTTEgM2gxNGExIDEgMCAwIDEgMSAxdjhhMSAxIDAgMCAxLTEgMUgxYTEgMSAwIDAgMS0xLTFWNGExIDEgMCAwIDEgMS0xem0yIDcuNWgxLjVWNy4zbDEuNSAxLjkgMS41LTEuOXYzLjJIOXYtNUg3LjVMNiA3LjQgNC41IDUuNUgzem04LjUgMEwxNCA4aC0xLjVWNS41aC0yVjhIOXoiLz48L3N2Zz4=",
+	"star":     "data:image/svg+xml;base64,PHN2ZyB4bWxucz0iaHR0cDovL3d3dy53My5vcmcvMjAwMC9zdmciIHZpZXdCb3g9IjAgMCAxNiAxNiI+PHBhdGggZmlsbD0iI2ZmZiIgZD0iTTggLjhsMi4yIDQuNiA1IC43LTMuNiAzLjUuOSA1TDggMTIuMmwtNC41IDIuNC45LTVMLjggNi4xbDUtLjd6Ii8+PC9zdmc+",
+}
+
+var hex_color_regexp = regexp.MustCompile(`^#?([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+var logo_data_uri_regexp = regexp.MustCompile(`^data:image/(png|svg\+xml|jpeg|gif);base64,([A-Za-z0-9+/]+={0,2})$`)
+
+// Functions available to every SVG template
+var BADGE_TEMPLATE_FUNCS = template.FuncMap{
+	"xml": EscapeXML,
+}
+
+// Control characters aren't allowed in XML 1.0 even when escaped
+func StripControlCharacters(text string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, text)
+}
+
+func EscapeXML(text string) string {
+	return template.HTMLEscapeString(StripControlCharacters(text))
+}
+
+func IsBadgeStyle(style string) bool {
+	for _, badge_style := range BADGE_STYLES {
+		if style == badge_style {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns a color that's safe to put in an SVG attribute: a hex color, with
+// or without the #, or one of BADGE_COLORS. ok is false for anything else.
+func ValidBadgeColor(color string) (string, bool) {
+	color = strings.TrimSpace(color)
+	if named, ok := BADGE_COLORS[strings.ToLower(color)]; ok {
+		return named, true
+	}
+	if hex_color_regexp.MatchString(color) {
+		return "#" + strings.ToUpper(strings.TrimPrefix(color, "#")), true
+	}
+	return "", false
+}
+
+// Drops control characters and truncates long labels. Labels are still
+// escaped by the templates with `xml`.
+func ValidBadgeLabel(label string) string {
+	label = strings.TrimSpace(StripControlCharacters(label))
+	if runes := []rune(label); len(runes) > MAX_BADGE_LABEL_LENGTH {
+		label = string(runes[:MAX_BADGE_LABEL_LENGTH])
+	}
+	return label
+}
+
+// Returns the data URI for a built-in logo name from BADGE_LOGOS, or a
+// base64 image data URI once it has been checked to decode.
+func ValidBadgeLogo(logo string) (string, bool) {
+	if data_uri, ok := BADGE_LOGOS[strings.ToLower(logo)]; ok {
+		return data_uri, true
+	}
+	if len(logo) > MAX_BADGE_LOGO_BYTES {
+		return "", false
+	}
+	matches := logo_data_uri_regexp.FindStringSubmatch(logo)
+	if matches == nil {
+		return "", false
+	}
+	if _, err := base64.StdEncoding.DecodeString(matches[2]); err != nil {
+		return "", false
+	}
+	return logo, true
+}
+
+// Sizes the label and value boxes of a badge around their text and logo.
+// The original ScoreMe badge has its value box on the left, at least 25
+// wide; the shields styles put the label on the left, and for-the-badge uses
+// uppercase, letter-spaced text and more padding.
+func LayoutBadge(score_svg ScoreSVG) ScoreSVG {
+	var logo_width float32
+	if score_svg.Logo != "" {
+		logo_width = BADGE_LOGO_SIZE + BADGE_LOGO_PADDING
+	}
+
+	if !IsBadgeStyle(score_svg.Style) {
+		score_svg.ValueWidth = RoundToTenth(float32(math.Max(25, float64(HELVETICA.TextWidth(score_svg.Value, 12)+4))))
+		score_svg.LabelWidth = RoundToTenth(logo_width + HELVETICA.TextWidth(score_svg.Label, 12) + 7)
+		score_svg.Width = score_svg.ValueWidth + score_svg.LabelWidth
+		score_svg.ValueX = RoundToTenth(score_svg.ValueWidth / 2)
+		score_svg.LogoX = score_svg.ValueWidth + 4
+		score_svg.LabelX = score_svg.ValueWidth + 4 + logo_width
+		return score_svg
+	}
+
+	var padding float32 = 10
+	label_width := VERDANA.TextWidth(score_svg.Label, 11)
+	value_width := VERDANA.TextWidth(score_svg.Value, 11)
+	if score_svg.Style == "for-the-badge" {
+		padding = 24
+		score_svg.Label = strings.ToUpper(score_svg.Label)
+		score_svg.Value = strings.ToUpper(score_svg.Value)
+		// 10px with 1px letter spacing, and a bold value
+		label_width = VERDANA.TextWidth(score_svg.Label, 10) + float32(len([]rune(score_svg.Label)))
+		value_width = VERDANA.TextWidth(score_svg.Value, 10)*1.1 + float32(len([]rune(score_svg.Value)))
+	}
+
+	label_box_width := logo_width + label_width + padding
+	if score_svg.Label == "" {
+		label_box_width = 0
+		if logo_width > 0 {
+			label_box_width = BADGE_LOGO_SIZE + padding
+		}
+	}
+
+	score_svg.LabelWidth = RoundToTenth(label_box_width)
+	score_svg.ValueWidth = RoundToTenth(value_width + padding)
+	score_svg.Width = score_svg.LabelWidth + score_svg.ValueWidth
+	score_svg.LogoX = RoundToTenth(padding / 2)
+	score_svg.LabelX = RoundToTenth(logo_width + (score_svg.LabelWidth-logo_width)/2)
+	score_svg.ValueX = RoundToTenth(score_svg.LabelWidth + score_svg.ValueWidth/2)
+	return score_svg
+}
+
+// Keeps SVG coordinates short and free of float32 noise
+func RoundToTenth(value float32) float32 {
+	return float32(math.Round(float64(value)*10) / 10)
+}
+
+func GetBadgeAsSVG(score_svg ScoreSVG) []byte {
+	doc, err := ExecuteTemplate("badges/"+score_svg.Style+".svg", score_svg)
+	HandleError(err)
+
+	return doc
+}
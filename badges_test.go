@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+// Each style, and the original ScoreMe badge, with one, two and three digit
+// values and the error badge
+func TestBadgeStylesMatchGoldenFiles(t *testing.T) {
+	styles := append([]string{""}, BADGE_STYLES...)
+	values := []string{"0", "5", "55", "100", "Err"}
+
+	for _, style := range styles {
+		for _, value := range values {
+			name := style
+			if name == "" {
+				name = "default"
+			}
+			style, value := style, value
+			t.Run(name+"/"+value, func(t *testing.T) {
+				options := ScoreOptionsFromQuery(url.Values{"style": {style}, "url": {"clayallsopp/readme-score"}})
+				var svg []byte
+				if value == "Err" {
+					svg = GetScoreErrorAsSVG(options)
+				} else {
+					total_score, _ := strconv.Atoi(value)
+					svg = GetScoreResponseAsSVG(Score{TotalScore: float32(total_score)}.AsScoreTemplate(options))
+				}
+				AssertGolden(t, "badges/"+name+"-"+value+".svg", svg)
+			})
+		}
+	}
+}
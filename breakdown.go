@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Layout of templates/breakdown.svg
+const BREAKDOWN_HEADER_HEIGHT = 18
+const BREAKDOWN_ROW_HEIGHT = 14
+const BREAKDOWN_BAR_WIDTH = 100
+const BREAKDOWN_POINTS_WIDTH = 44
+const BREAKDOWN_FONT_SIZE = 10
+
+var BREAKDOWN_LABELS = map[string]string{
+	"cumulative_code_block_length": "Code length",
+	"has_lists?":                   "Lists",
+	"low_code_block_penalty":       "Short code penalty",
+	"number_of_code_blocks":        "Code blocks",
+	"number_of_gifs":               "GIFs",
+	"number_of_images":             "Images",
+	"number_of_non_code_sections":  "Sections",
+}
+
+type BreakdownSVG struct {
+	Title  string
+	Width  float32
+	Height float32
+	Header ScoreSVG
+	// Where the bars and their points start
+	BarX    float32
+	PointsX float32
+	Rows    []BreakdownBar
+}
+
+type BreakdownBar struct {
+	Label     string
+	Points    string
+	Y         float32
+	FillWidth float32
+	Color     string
+}
+
+func BreakdownLabel(key string) string {
+	if label, ok := BREAKDOWN_LABELS[key]; ok {
+		return label
+	}
+	label := strings.Replace(strings.TrimSuffix(key, "?"), "_", " ", -1)
+	if label == "" {
+		return key
+	}
+	first, size := utf8.DecodeRuneInString(label)
+	return string(unicode.ToUpper(first)) + label[size:]
+}
+
+// Fills each bar by points/max from the human breakdown. Penalties have a
+// negative max, so their bar grows with the penalty and is always red.
+func BreakdownBars(score Score, options ScoreOptions) []BreakdownBar {
+	keys := []string{}
+	for key := range score.HumanBreakdown {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	bars := []BreakdownBar{}
+	for i, key := range keys {
+		row := BreakdownRow{Key: key}
+		if values := score.HumanBreakdown[key]; len(values) > 1 {
+			row.Points, row.Max = values[0], values[1]
+		}
+		var ratio float32
+		if row.Max != 0 {
+			ratio = row.Points / row.Max
+		}
+		if ratio < 0 {
+			ratio = 0
+		} else if ratio > 1 {
+			ratio = 1
+		}
+
+		color := options.ColorScale.ColorFor(ratio * 100)
+		if row.Max < 0 {
+			color = COLOR_SCALES["default"].Colors[0]
+		}
+		bars = append(bars, BreakdownBar{
+			Label:     BreakdownLabel(key),
+			Points:    fmt.Sprintf("%s/%s", FormatScoreValue(RoundToTenth(row.Points)), FormatScoreValue(row.Max)),
+			Y:         float32(BREAKDOWN_HEADER_HEIGHT + 4 + i*BREAKDOWN_ROW_HEIGHT),
+			FillWidth: RoundToTenth(ratio * BREAKDOWN_BAR_WIDTH),
+			Color:     color,
+		})
+	}
+	return bars
+}
+
+// e.g. "Code blocks 15/15, Images 0/15"
+func BreakdownDescription(bars []BreakdownBar) string {
+	descriptions := make([]string, len(bars))
+	for i, bar := range bars {
+		descriptions[i] = bar.Label + " " + bar.Points
+	}
+	return strings.Join(descriptions, ", ")
+}
+
+func (score Score) AsBreakdownTemplate(options ScoreOptions) BreakdownSVG {
+	bars := BreakdownBars(score, options)
+	var label_width float32
+	for _, bar := range bars {
+		if width := HELVETICA.TextWidth(bar.Label, BREAKDOWN_FONT_SIZE); width > label_width {
+			label_width = width
+		}
+	}
+
+	header := ThemeBadge(LayoutBadge(score.AsScoreTemplate(ScoreOptions{
+		Label:      options.Label,
+		LabelColor: options.LabelColor,
+		Color:      options.Color,
+		ColorScale: options.ColorScale,
+		URL:        options.URL,
+	})))
+	breakdown_svg := BreakdownSVG{
+		Title:  header.Title + " (" + BreakdownDescription(bars) + ")",
+		Header: header,
+		BarX:   RoundToTenth(label_width + 8),
+		Rows:   bars,
+	}
+	breakdown_svg.PointsX = breakdown_svg.BarX + BREAKDOWN_BAR_WIDTH + 4
+	breakdown_svg.Width = breakdown_svg.PointsX + BREAKDOWN_POINTS_WIDTH
+	if header.Width > breakdown_svg.Width {
+		breakdown_svg.Width = header.Width
+	}
+	breakdown_svg.Height = float32(BREAKDOWN_HEADER_HEIGHT + 4 + len(bars)*BREAKDOWN_ROW_HEIGHT + 2)
+	return breakdown_svg
+}
+
+func GetScoreBreakdownAsSVG(breakdown_svg BreakdownSVG) []byte {
+	doc, err := ExecuteTemplate("breakdown.svg", breakdown_svg)
+	HandleError(err)
+
+	return doc
+}
+
+func (server *Server) GetBreakdown(res http.ResponseWriter, req *http.Request, params Params) {
+	query_params := req.URL.Query()
+	options := ScoreOptionsFromQuery(query_params)
+	_, force := query_params["force"]
+	SetContentTypeForFormat(res, "svg")
+	var score *Score
+	var err error
+
+	url_or_slug := strings.ToLower(query_params.Get("url"))
+	if url_or_slug == "" {
+		url_or_slug = strings.ToLower(query_params.Get("github"))
+	}
+	if url_or_slug == "" {
+		err = errors.New("No value for :url or :github query parameter")
+	}
+	if err == nil {
+		score, err = server.GetScoreForUrlOrSlug(req.Context(), url_or_slug, force, query_params.Get("sha"))
+	}
+	HandleError(err)
+
+	if score == nil {
+		WriteSVGWithETag(res, GetScoreErrorAsSVG(ScoreOptions{Label: options.Label, LabelColor: options.LabelColor}))
+	} else {
+		WriteSVGWithETag(res, GetScoreBreakdownAsSVG(score.AsBreakdownTemplate(options)))
+	}
+}
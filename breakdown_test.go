@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestBreakdownLabel(t *testing.T) {
+	tests := []struct {
+		key   string
+		label string
+	}{
+		{"number_of_code_blocks", "Code blocks"},
+		{"has_lists?", "Lists"},
+		{"new_criterion", "New criterion"},
+		{"has_tables?", "Has tables"},
+		{"émojis", "Émojis"},
+		{"", ""},
+		{"?", "?"},
+		{"_", " "},
+	}
+	for _, test := range tests {
+		if label := BreakdownLabel(test.key); label != test.label {
+			t.Errorf("BreakdownLabel(%q) = %q, want %q", test.key, label, test.label)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const CLI_NAME = "readme-score"
+
+// README file names in the order we prefer them when a directory has several
+var README_EXTENSIONS = []string{".md", ".markdown", ".mdown", ".mkdn", "", ".txt"}
+
+// The CLI shares this binary with the server. It runs when the binary is
+// built or linked as `readme-score`, or when the first argument is `score`.
+func IsCLIInvocation(args []string) bool {
+	if filepath.Base(args[0]) == CLI_NAME {
+		return true
+	}
+	return len(args) > 1 && args[1] == "score"
+}
+
+func ReadmeRank(name string) int {
+	lower := strings.ToLower(name)
+	if !strings.HasPrefix(lower, "readme") {
+		return -1
+	}
+	for rank, ext := range README_EXTENSIONS {
+		if lower == "readme"+ext {
+			return rank
+		}
+	}
+	return -1
+}
+
+func FindReadmeInDirectory(dir string) (string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	var candidates []string
+	for _, entry := range entries {
+		if !entry.IsDir() && ReadmeRank(entry.Name()) >= 0 {
+			candidates = append(candidates, entry.Name())
+		}
+	}
+	if len(candidates) == 0 {
+		return "", errors.New("No README found in " + dir)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return ReadmeRank(candidates[i]) < ReadmeRank(candidates[j])
+	})
+	return filepath.Join(dir, candidates[0]), nil
+}
+
+func GitTopLevel(dir string) (string, error) {
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "--show-toplevel").Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Resolves a file, directory or anywhere inside a git working tree to the
+// README that should be scored.
+func FindReadme(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return path, nil
+	}
+
+	readme_path, err := FindReadmeInDirectory(path)
+	if err != nil {
+		if top_level, git_err := GitTopLevel(path); git_err == nil {
+			return FindReadmeInDirectory(top_level)
+		}
+	}
+	return readme_path, err
+}
+
+// get_score.rb next to the binary (or the binary `readme-score` links to),
+// so the CLI works from any directory. Falls back to DEFAULT_SCORER_PATH.
+func DefaultCLIScorerPath() string {
+	executable, err := os.Executable()
+	if err == nil {
+		executable, err = filepath.EvalSymlinks(executable)
+	}
+	if err != nil {
+		return DEFAULT_SCORER_PATH
+	}
+	scorer_path := filepath.Join(filepath.Dir(executable), filepath.Base(DEFAULT_SCORER_PATH))
+	if _, err := os.Stat(scorer_path); err != nil {
+		return DEFAULT_SCORER_PATH
+	}
+	return scorer_path
+}
+
+func RunCLI(args []string) int {
+	if len(args) > 0 && args[0] == "score" {
+		args = args[1:]
+	}
+
+	flags := flag.NewFlagSet(CLI_NAME, flag.ContinueOnError)
+	min_score := flags.Float64("min", 0, "exit non-zero when the score is below this value")
+	as_json := flags.Bool("json", false, "print JSON like /score.json instead of text")
+	human_breakdown := flags.Bool("human_breakdown", false, "use the human breakdown in JSON output")
+	suggestions := flags.Bool("suggestions", false, "include suggestions for improving the README")
+	grade := flags.Bool("grade", false, "show a letter grade instead of the number")
+	scorer := flags.String("scorer", DefaultCLIScorerPath(), "path to get_score.rb")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] [README file, directory or git checkout]\n", CLI_NAME)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err == flag.ErrHelp {
+		return 0
+	} else if err != nil {
+		return 2
+	}
+	ScorerPath = *scorer
+	ScorerStderr = os.Stderr
+
+	path := "."
+	if flags.NArg() > 0 {
+		path = flags.Arg(0)
+	}
+
+	readme_path, err := FindReadme(path)
+	var markdown []byte
+	if err == nil {
+		markdown, err = ioutil.ReadFile(readme_path)
+	}
+	var score *Score
+	if err == nil {
+		var scoreJson string
+		if scoreJson, err = RunScorer(context.Background(), strings.NewReader(string(markdown)), "--markdown"); err == nil {
+			score, err = ParseScoreJson(scoreJson)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", CLI_NAME, err)
+		if *as_json {
+			fmt.Println(string(GetScoreErrorAsJson(path)))
+		} else {
+			fmt.Println("error")
+		}
+		return 2
+	}
+
+	options := ScoreOptions{HumanBreakdown: *human_breakdown, Suggestions: *suggestions}
+	if *grade {
+		score.Display = GradeForScore(score.TotalScore, DefaultGradeCutoffs)
+	}
+	if *as_json {
+		fmt.Println(string(GetScoreResponseAsJson(*score, readme_path, options)))
+	} else {
+		fmt.Println(string(GetScoreResponseAsText(*score, options)))
+	}
+
+	if float64(score.TotalScore) < *min_score {
+		fmt.Fprintf(os.Stderr, "%s: score %d for %s is below the minimum of %g\n", CLI_NAME, int(score.TotalScore), readme_path, *min_score)
+		return 1
+	}
+	return 0
+}
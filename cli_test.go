@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func WriteTestFiles(t *testing.T, dir string, names ...string) {
+	for _, name := range names {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("# Test\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFindReadme(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    []string
+		path     string
+		expected string
+	}{
+		{"file", []string{"docs/guide.md"}, "docs/guide.md", "docs/guide.md"},
+		{"directory", []string{"README.md"}, ".", "README.md"},
+		{"lower case", []string{"readme.markdown"}, ".", "readme.markdown"},
+		{"mixed case", []string{"ReadMe.txt"}, ".", "ReadMe.txt"},
+		{"preferred extension", []string{"README.txt", "README", "Readme.md"}, ".", "Readme.md"},
+		{"not a readme", []string{"README-old.md", "README"}, ".", "README"},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			WriteTestFiles(t, dir, test.files...)
+			readme_path, err := FindReadme(filepath.Join(dir, test.path))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := filepath.Join(dir, test.expected); readme_path != expected {
+				t.Errorf("Got %s, expected %s", readme_path, expected)
+			}
+		})
+	}
+}
+
+// Inside a git working tree, the README at its top level is used when the
+// directory has none of its own
+func TestFindReadmeInGitTopLevel(t *testing.T) {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := exec.Command("git", "init", "-q", dir).Run(); err != nil {
+		t.Skip("git isn't available: ", err)
+	}
+	WriteTestFiles(t, dir, "README.md", "src/main.go")
+
+	readme_path, err := FindReadme(filepath.Join(dir, "src"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := filepath.Join(dir, "README.md"); readme_path != expected {
+		t.Errorf("Got %s, expected %s", readme_path, expected)
+	}
+	if _, err := FindReadme(t.TempDir()); err == nil {
+		t.Error("Expected an error outside a git working tree without a README")
+	}
+}
+
+// The stub scorer in testdata/compat always scores 55
+func TestRunCLIExitCodes(t *testing.T) {
+	previous_scorer_path, previous_stdout := ScorerPath, os.Stdout
+	defer func() {
+		ScorerPath, os.Stdout, ScorerStderr = previous_scorer_path, previous_stdout, nil
+	}()
+	os.Stdout, _ = os.Open(os.DevNull)
+
+	dir := t.TempDir()
+	WriteTestFiles(t, dir, "README.md")
+	scorer := "--scorer=testdata/compat/get_score.sh"
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"score", []string{scorer, dir}, 0},
+		{"above minimum", []string{scorer, "--min=50", dir}, 0},
+		{"below minimum", []string{scorer, "--min=60", dir}, 1},
+		{"score subcommand", []string{"score", scorer, "--min=60", dir}, 1},
+		{"missing README", []string{scorer, filepath.Join(dir, "missing")}, 2},
+		{"scorer failure", []string{"--scorer=testdata/missing.rb", dir}, 2},
+		{"bad flag", []string{"--nope"}, 2},
+		{"help", []string{"-h"}, 0},
+	}
+	for _, test := range tests {
+		if code := RunCLI(test.args); code != test.code {
+			t.Errorf("%s: exited %d, expected %d", test.name, code, test.code)
+		}
+	}
+}
+
+func TestRunScorerStderr(t *testing.T) {
+	previous_scorer_path := ScorerPath
+	defer func() { ScorerPath, ScorerStderr = previous_scorer_path, nil }()
+
+	ScorerPath = filepath.Join(t.TempDir(), "get_score.sh")
+	script := "#!/bin/sh\necho 'Could not find gem redcarpet' >&2\nexit 1\n"
+	if err := ioutil.WriteFile(ScorerPath, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	var stderr bytes.Buffer
+	ScorerStderr = &stderr
+	if _, err := RunScorer(context.Background(), nil); err == nil {
+		t.Error("Expected the scorer to fail")
+	}
+	if !strings.Contains(stderr.String(), "Could not find gem redcarpet") {
+		t.Errorf("Scorer's stderr wasn't passed on, got %q", stderr.String())
+	}
+}
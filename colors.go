@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Maps a score to a color. Scores below Thresholds[i] (and not below any
+// earlier threshold) get Colors[i], and anything higher gets the last color,
+// so there is always one more color than threshold.
+type ColorScale struct {
+	Thresholds []float32
+	Colors     []string
+	// Blends between neighbouring colors instead of stepping at thresholds
+	Interpolate bool
+}
+
+var COLOR_SCALES = map[string]ColorScale{
+	"default": {
+		Thresholds: []float32{25, 80},
+		Colors:     []string{"#E74C3C", "#F39C12", "#2ECC71"},
+	},
+	"strict": {
+		Thresholds: []float32{50, 90},
+		Colors:     []string{"#E74C3C", "#F39C12", "#2ECC71"},
+	},
+	"gradient": {
+		Thresholds: []float32{20, 40, 60, 80},
+		Colors:     []string{"#E74C3C", "#E67E22", "#F1C40F", "#A4C639", "#2ECC71"},
+	},
+	"shields": {
+		Thresholds: []float32{20, 40, 60, 80},
+		Colors:     []string{"#E05D44", "#FE7D37", "#DFB317", "#A4A61D", "#4C1"},
+	},
+}
+
+// Used when a request doesn't ask for a scale; see ColorScaleFromEnv
+var DefaultColorScale = COLOR_SCALES["default"]
+
+func (scale ColorScale) Validate() error {
+	if len(scale.Colors) != len(scale.Thresholds)+1 {
+		return fmt.Errorf("Color scales need one more color than thresholds (got %d colors and %d thresholds)", len(scale.Colors), len(scale.Thresholds))
+	}
+	for i := 1; i < len(scale.Thresholds); i++ {
+		if scale.Thresholds[i] <= scale.Thresholds[i-1] {
+			return errors.New("Color scale thresholds must be increasing")
+		}
+	}
+	return nil
+}
+
+func ParseThresholds(value string) ([]float32, error) {
+	thresholds := []float32{}
+	for _, part := range strings.Split(value, ",") {
+		threshold, err := strconv.ParseFloat(strings.TrimSpace(part), 32)
+		if err != nil {
+			return nil, errors.New("Could not parse threshold " + part)
+		}
+		thresholds = append(thresholds, float32(threshold))
+	}
+	return thresholds, nil
+}
+
+func ParseColors(value string) ([]string, error) {
+	colors := []string{}
+	for _, part := range strings.Split(value, ",") {
+		color, ok := ValidBadgeColor(part)
+		if !ok {
+			return nil, errors.New("Invalid color " + part)
+		}
+		colors = append(colors, color)
+	}
+	return colors, nil
+}
+
+// Starts from the named `color_scale` (or base), then applies `thresholds`,
+// `colors` and `interpolate`. The same names are used for query parameters
+// and the badges config.
+func ParseColorScale(base ColorScale, get func(name string) string) (ColorScale, error) {
+	scale := base
+	if name := get("color_scale"); name != "" {
+		named, ok := COLOR_SCALES[strings.ToLower(name)]
+		if !ok {
+			return base, errors.New("Unknown color scale " + name)
+		}
+		scale = named
+	}
+
+	var err error
+	if thresholds := get("thresholds"); thresholds != "" {
+		if scale.Thresholds, err = ParseThresholds(thresholds); err != nil {
+			return base, err
+		}
+	}
+	if colors := get("colors"); colors != "" {
+		if scale.Colors, err = ParseColors(colors); err != nil {
+			return base, err
+		}
+	}
+	if interpolate := get("interpolate"); interpolate != "" {
+		scale.Interpolate = interpolate == "true"
+	}
+
+	if err = scale.Validate(); err != nil {
+		return base, err
+	}
+	return scale, nil
+}
+
+// Falls back to DefaultColorScale when a parameter is invalid, so a badge is
+// always drawn.
+func ColorScaleFromQuery(query_params url.Values) ColorScale {
+	scale, _ := ParseColorScale(DefaultColorScale, query_params.Get)
+	return scale
+}
+
+func ParseHexColor(color string) (r, g, b float64, ok bool) {
+	hex, ok := ValidBadgeColor(color)
+	if !ok {
+		return 0, 0, 0, false
+	}
+	hex = strings.TrimPrefix(hex, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	value, _ := strconv.ParseUint(hex, 16, 32)
+	return float64(value >> 16 & 0xFF), float64(value >> 8 & 0xFF), float64(value & 0xFF), true
+}
+
+func MixColors(from string, to string, amount float64) string {
+	from_r, from_g, from_b, ok := ParseHexColor(from)
+	to_r, to_g, to_b, to_ok := ParseHexColor(to)
+	if !ok || !to_ok {
+		return from
+	}
+	mix := func(a, b float64) int {
+		return int(a + (b-a)*amount + 0.5)
+	}
+	return fmt.Sprintf("#%02X%02X%02X", mix(from_r, to_r), mix(from_g, to_g), mix(from_b, to_b))
+}
+
+// When interpolating, the first color sits at 0, the last at 100 and each
+// color in between at the middle of its band.
+func (scale ColorScale) ColorStops() []float32 {
+	stops := make([]float32, len(scale.Colors))
+	for i := range stops {
+		if i == 0 {
+			stops[i] = 0
+		} else if i == len(stops)-1 {
+			stops[i] = 100
+		} else {
+			stops[i] = (scale.Thresholds[i-1] + scale.Thresholds[i]) / 2
+		}
+	}
+	return stops
+}
+
+func (scale ColorScale) ColorFor(value float32) string {
+	if len(scale.Colors) == 0 {
+		scale = DefaultColorScale
+	}
+
+	if !scale.Interpolate || len(scale.Colors) == 1 {
+		for i, threshold := range scale.Thresholds {
+			if value < threshold {
+				return scale.Colors[i]
+			}
+		}
+		return scale.Colors[len(scale.Colors)-1]
+	}
+
+	stops := scale.ColorStops()
+	if value <= stops[0] {
+		return scale.Colors[0]
+	}
+	for i := 1; i < len(stops); i++ {
+		if value <= stops[i] {
+			amount := float64((value - stops[i-1]) / (stops[i] - stops[i-1]))
+			return MixColors(scale.Colors[i-1], scale.Colors[i], amount)
+		}
+	}
+	return scale.Colors[len(scale.Colors)-1]
+}
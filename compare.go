@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DEFAULT_GITHUB_API_URL = "https://api.github.com"
+
+// Set from GitHubConfig
+var GithubAPIURL = DEFAULT_GITHUB_API_URL
+var GithubToken = ""
+
+var github_client = &http.Client{Timeout: 15 * time.Second}
+
+// The characters GitHub allows in user, organization and repository names
+var github_name_regexp = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+type ScoreDelta struct {
+	Base  float32 `json:"base"`
+	Head  float32 `json:"head"`
+	Delta float32 `json:"delta"`
+}
+
+type CompareSide struct {
+	URL   string  `json:"url"`
+	Ref   string  `json:"ref,omitempty"`
+	Score float32 `json:"score"`
+}
+
+type CompareResponse struct {
+	Base           CompareSide           `json:"base"`
+	Head           CompareSide           `json:"head"`
+	TotalDelta     float32               `json:"total_delta"`
+	Breakdown      map[string]ScoreDelta `json:"breakdown"`
+	HumanBreakdown map[string]ScoreDelta `json:"human_breakdown"`
+}
+
+// Turns "owner/repo", "github.com/owner/repo" or a full GitHub URL into
+// "owner/repo". The owner and repo are checked, as they become part of the
+// API path requested with our token.
+func GithubSlugForUrlOrSlug(url_or_slug string) (string, error) {
+	slug := strings.TrimSpace(url_or_slug)
+	for _, prefix := range []string{"https://", "http://", "www.", "github.com/"} {
+		slug = strings.TrimPrefix(slug, prefix)
+	}
+	slug = strings.TrimSuffix(strings.TrimSuffix(slug, "/"), ".git")
+	parts := strings.Split(slug, "/")
+	if len(parts) < 2 || !ValidGithubName(parts[0]) || !ValidGithubName(parts[1]) {
+		return "", errors.New("Not a GitHub repository: " + url_or_slug)
+	}
+	return parts[0] + "/" + parts[1], nil
+}
+
+func ValidGithubName(name string) bool {
+	return github_name_regexp.MatchString(name) && name != "." && name != ".."
+}
+
+// Fetches the raw README of a GitHub repository at the given ref.
+// Set a GitHub token to avoid the anonymous rate limit.
+func FetchGithubReadme(ctx context.Context, slug string, ref string) (markdown string, err error) {
+	ctx, span := StartSpan(ctx, "README fetch", SPAN_KIND_CLIENT)
+	defer func() {
+		span.RecordError(err)
+		span.Finish()
+	}()
+	owner_and_repo := strings.SplitN(slug, "/", 2)
+	if len(owner_and_repo) != 2 {
+		return "", errors.New("Not a GitHub repository: " + slug)
+	}
+	readme_url := GithubAPIURL + "/repos/" + url.PathEscape(owner_and_repo[0]) + "/" + url.PathEscape(owner_and_repo[1]) + "/readme?ref=" + url.QueryEscape(ref)
+	span.SetAttribute("http.request.method", "GET")
+	span.SetAttribute("url.full", readme_url)
+	req, err := http.NewRequest("GET", readme_url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github.raw")
+	if GithubToken != "" {
+		req.Header.Set("Authorization", "token "+GithubToken)
+	}
+	if traceparent := TraceparentFromContext(ctx); traceparent != "" {
+		req.Header.Set(TRACEPARENT_HEADER, traceparent)
+	}
+
+	github_res, err := github_client.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	defer github_res.Body.Close()
+	span.SetAttribute("http.response.status_code", github_res.StatusCode)
+	if github_res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GitHub returned %d for the README of %s at %s", github_res.StatusCode, slug, ref)
+	}
+	markdown_bytes, err := ioutil.ReadAll(github_res.Body)
+	return string(markdown_bytes), err
+}
+
+func (server *Server) GetScoreForGithubRef(ctx context.Context, slug string, ref string, force bool) (*Score, error) {
+	markdown, err := FetchGithubReadme(ctx, slug, ref)
+	if err != nil {
+		return nil, err
+	}
+	return server.GetScoreForMarkdown(ctx, markdown, force)
+}
+
+func BreakdownDeltas(base map[string]float32, head map[string]float32) map[string]ScoreDelta {
+	deltas := map[string]ScoreDelta{}
+	for key, value := range base {
+		deltas[key] = ScoreDelta{Base: value, Head: head[key], Delta: head[key] - value}
+	}
+	for key, value := range head {
+		if _, ok := base[key]; !ok {
+			deltas[key] = ScoreDelta{Head: value, Delta: value}
+		}
+	}
+	return deltas
+}
+
+// Human breakdown values are [points, max points]; only the points move.
+func HumanBreakdownPoints(human_breakdown map[string][]float32) map[string]float32 {
+	points := map[string]float32{}
+	for key, values := range human_breakdown {
+		if len(values) > 0 {
+			points[key] = values[0]
+		}
+	}
+	return points
+}
+
+func CompareScores(base *Score, base_side CompareSide, head *Score, head_side CompareSide) CompareResponse {
+	base_side.Score = base.TotalScore
+	head_side.Score = head.TotalScore
+	return CompareResponse{
+		Base:           base_side,
+		Head:           head_side,
+		TotalDelta:     head.TotalScore - base.TotalScore,
+		Breakdown:      BreakdownDeltas(base.Breakdown, head.Breakdown),
+		HumanBreakdown: BreakdownDeltas(HumanBreakdownPoints(base.HumanBreakdown), HumanBreakdownPoints(head.HumanBreakdown)),
+	}
+}
+
+func SortedDeltaKeys(deltas map[string]ScoreDelta) []string {
+	keys := make([]string, 0, len(deltas))
+	for key := range deltas {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func FormatScoreValue(value float32) string {
+	return strconv.FormatFloat(float64(value), 'f', -1, 32)
+}
+
+func FormatScoreDelta(delta float32) string {
+	if delta > 0 {
+		return "+" + FormatScoreValue(delta)
+	}
+	return FormatScoreValue(delta)
+}
+
+func CompareSideName(side CompareSide) string {
+	if side.Ref != "" {
+		return side.Ref
+	}
+	return side.URL
+}
+
+func GetCompareResponseAsText(comparison CompareResponse, human_breakdown bool) []byte {
+	var doc bytes.Buffer
+	deltas := comparison.Breakdown
+	if human_breakdown {
+		deltas = comparison.HumanBreakdown
+	}
+	fmt.Fprintf(&doc, "total_score %s -> %s (%s)\n", FormatScoreValue(comparison.Base.Score), FormatScoreValue(comparison.Head.Score), FormatScoreDelta(comparison.TotalDelta))
+	for _, key := range SortedDeltaKeys(deltas) {
+		delta := deltas[key]
+		fmt.Fprintf(&doc, "%s %s -> %s (%s)\n", key, FormatScoreValue(delta.Base), FormatScoreValue(delta.Head), FormatScoreDelta(delta.Delta))
+	}
+	return doc.Bytes()
+}
+
+// Renders a Markdown table suitable for posting as a pull request comment.
+// Refs, URLs and keys come from the query, so anything that could end the
+// cell or row, or start a link or code span, is escaped
+var markdown_cell_replacer = strings.NewReplacer(
+	"\\", "\\\\",
+	"|", "\\|",
+	"`", "\\`",
+	"[", "\\[",
+	"]", "\\]",
+	"\r\n", " ",
+	"\r", " ",
+	"\n", " ",
+)
+
+func MarkdownTableCell(text string) string {
+	return markdown_cell_replacer.Replace(text)
+}
+
+func GetCompareResponseAsMarkdown(comparison CompareResponse, human_breakdown bool) []byte {
+	var doc bytes.Buffer
+	deltas := comparison.Breakdown
+	if human_breakdown {
+		deltas = comparison.HumanBreakdown
+	}
+	fmt.Fprintf(&doc, "| | %s | %s | Change |\n", MarkdownTableCell(CompareSideName(comparison.Base)), MarkdownTableCell(CompareSideName(comparison.Head)))
+	doc.WriteString("|---|---:|---:|---:|\n")
+	fmt.Fprintf(&doc, "| **Score** | **%s** | **%s** | **%s** |\n", FormatScoreValue(comparison.Base.Score), FormatScoreValue(comparison.Head.Score), FormatScoreDelta(comparison.TotalDelta))
+	for _, key := range SortedDeltaKeys(deltas) {
+		delta := deltas[key]
+		fmt.Fprintf(&doc, "| %s | %s | %s | %s |\n", MarkdownTableCell(key), FormatScoreValue(delta.Base), FormatScoreValue(delta.Head), FormatScoreDelta(delta.Delta))
+	}
+	return doc.Bytes()
+}
+
+func GetCompareErrorAsJson(base string, head string) []byte {
+	res := &ErrorResponse{
+		Error: "Could not compare " + base + " and " + head}
+	return MarshalToJsonBytes(res)
+}
+
+// Compares either two URLs or slugs (`base` and `head`), or one GitHub
+// repository (`url`) at two refs (`base_ref` and `head_ref`).
+func (server *Server) GetCompare(res http.ResponseWriter, req *http.Request, params Params) {
+	query_params := req.URL.Query()
+	human_breakdown := query_params.Get("human_breakdown") == "true"
+	_, force := query_params["force"]
+	format := params["format"]
+	SetContentTypeForFormat(res, format)
+	var base_side, head_side CompareSide
+	var base_score, head_score *Score
+	var err error
+
+	if url_or_slug := strings.ToLower(query_params.Get("url")); url_or_slug != "" {
+		var slug string
+		base_side = CompareSide{URL: url_or_slug, Ref: query_params.Get("base_ref")}
+		head_side = CompareSide{URL: url_or_slug, Ref: query_params.Get("head_ref")}
+		if base_side.Ref == "" || head_side.Ref == "" {
+			err = errors.New("No value for :base_ref or :head_ref query parameter")
+		}
+		if err == nil {
+			slug, err = GithubSlugForUrlOrSlug(url_or_slug)
+		}
+		if err == nil {
+			base_score, err = server.GetScoreForGithubRef(req.Context(), slug, base_side.Ref, force)
+		}
+		if err == nil {
+			head_score, err = server.GetScoreForGithubRef(req.Context(), slug, head_side.Ref, force)
+		}
+	} else {
+		base_side = CompareSide{URL: strings.ToLower(query_params.Get("base"))}
+		head_side = CompareSide{URL: strings.ToLower(query_params.Get("head"))}
+		if base_side.URL == "" || head_side.URL == "" {
+			err = errors.New("No value for :base or :head query parameter")
+		}
+		if err == nil {
+			base_score, err = server.GetScoreForUrlOrSlug(req.Context(), base_side.URL, force, "")
+		}
+		if err == nil {
+			head_score, err = server.GetScoreForUrlOrSlug(req.Context(), head_side.URL, force, "")
+		}
+	}
+	HandleError(err)
+
+	if base_score == nil || head_score == nil {
+		if format == "txt" || format == "md" {
+			res.Write([]byte("error"))
+		} else {
+			res.Write(GetCompareErrorAsJson(CompareSideName(base_side), CompareSideName(head_side)))
+		}
+		return
+	}
+
+	comparison := CompareScores(base_score, base_side, head_score, head_side)
+	if format == "txt" {
+		res.Write(GetCompareResponseAsText(comparison, human_breakdown))
+	} else if format == "md" {
+		res.Write(GetCompareResponseAsMarkdown(comparison, human_breakdown))
+	} else {
+		res.Write(MarshalToJsonBytes(&comparison))
+	}
+}
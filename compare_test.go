@@ -0,0 +1,36 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompareMarkdownEscapesCells(t *testing.T) {
+	comparison := CompareResponse{
+		Base: CompareSide{URL: "rails/rails", Ref: "main"},
+		Head: CompareSide{URL: "rails/rails", Ref: "x|y`[click](https://evil.example)`\n| **Score** | 100 |"},
+		Breakdown: map[string]ScoreDelta{
+			"has_lists?":  {Base: 10, Head: 10},
+			"a|b[c](d)\\": {Base: 0, Head: 5, Delta: 5},
+		},
+	}
+	markdown := string(GetCompareResponseAsMarkdown(comparison, false))
+
+	lines := strings.Split(strings.TrimSuffix(markdown, "\n"), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("Expected 5 rows, got %d:\n%s", len(lines), markdown)
+	}
+	for _, line := range lines {
+		// Every cell boundary is an unescaped pipe
+		if cells := len(strings.Split(strings.Replace(line, "\\|", "", -1), "|")); cells != 6 {
+			t.Errorf("Row has %d cells instead of 4: %s", cells-2, line)
+		}
+	}
+	expected_header := "| | main | x\\|y\\`\\[click\\](https://evil.example)\\` \\| **Score** \\| 100 \\| | Change |"
+	if lines[0] != expected_header {
+		t.Errorf("Got header %s, expected %s", lines[0], expected_header)
+	}
+	if !strings.Contains(markdown, "| a\\|b\\[c\\](d)\\\\ | 0 | 5 | +5 |") {
+		t.Errorf("Breakdown key wasn't escaped:\n%s", markdown)
+	}
+}
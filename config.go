@@ -0,0 +1,583 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Settings are read in this order, each overriding the last: defaults, the
+// TOML config file, environment variables, then command-line flags. Each
+// setting's flag is its section and key, e.g. --redis.max_active.
+//
+// Tags: `toml` is the key in the file, `env` the environment variables
+// (earlier names win), `help` the flag's usage, and `secret` marks values
+// to redact when printing: "true" hides the whole value, "url" just a URL's
+// password and "api_keys" the keys in CORS API key origins.
+type Config struct {
+	Server    ServerConfig    `toml:"server"`
+	TLS       TLSConfig       `toml:"tls"`
+	Redis     RedisConfig     `toml:"redis"`
+	Cache     CacheConfig     `toml:"cache"`
+	History   HistoryConfig   `toml:"history"`
+	CORS      CORSConfig      `toml:"cors"`
+	RateLimit RateLimitConfig `toml:"rate_limit"`
+	Scorer    ScorerConfig    `toml:"scorer"`
+	Badges    BadgesConfig    `toml:"badges"`
+	GitHub    GitHubConfig    `toml:"github"`
+	Tracing   TracingConfig   `toml:"tracing"`
+}
+
+type ServerConfig struct {
+	Host            string        `toml:"host" env:"HOST" help:"interface to listen on (all by default)"`
+	Port            int           `toml:"port" env:"PORT" help:"port to listen on"`
+	Env             string        `toml:"env" env:"MARTINI_ENV" help:"development or production"`
+	ErrorMode       string        `toml:"error_mode" env:"ERROR_MODE" help:"panic on request errors (a 500), or ignore them and respond with an error badge"`
+	ShutdownTimeout time.Duration `toml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" help:"how long to wait for requests and scorers on shutdown"`
+	TemplatesDir    string        `toml:"templates_dir" env:"TEMPLATES_DIR" help:"directory of templates replacing the built-in ones"`
+}
+
+type TLSConfig struct {
+	CertFile       string        `toml:"cert_file" env:"TLS_CERT_FILE" help:"PEM certificate chain; serves HTTPS when set"`
+	KeyFile        string        `toml:"key_file" env:"TLS_KEY_FILE" help:"PEM private key for cert_file"`
+	ReloadInterval time.Duration `toml:"reload_interval" env:"TLS_RELOAD_INTERVAL" help:"how often to check the certificate files for renewals, 0 to never reload"`
+	MinVersion     string        `toml:"min_version" env:"TLS_MIN_VERSION" help:"lowest TLS version accepted: 1.2 or 1.3"`
+	HTTP2          bool          `toml:"http2" env:"TLS_HTTP2" help:"serve HTTP/2 to clients that support it"`
+	ClientCAFile   string        `toml:"client_ca_file" env:"TLS_CLIENT_CA_FILE" help:"PEM CA certificates; when set, admin paths need a client certificate signed by one"`
+	AdminPaths     []string      `toml:"admin_paths" env:"TLS_ADMIN_PATHS" help:"paths needing a client certificate when client_ca_file is set"`
+}
+
+type RedisConfig struct {
+	URL              string        `toml:"url" env:"REDIS_URL,REDISCLOUD_URL" secret:"url" help:"redis:// or rediss:// (TLS) URL of the score cache, with an optional username, password and database"`
+	SentinelAddrs    []string      `toml:"sentinel_addrs" env:"REDIS_SENTINEL_ADDRS" help:"host:port of each Sentinel; when set, the cache is the master they report and url's host is ignored"`
+	SentinelMaster   string        `toml:"sentinel_master" env:"REDIS_SENTINEL_MASTER" help:"name of the master Sentinel monitors"`
+	SentinelPassword string        `toml:"sentinel_password" env:"REDIS_SENTINEL_PASSWORD" secret:"true" help:"password for the Sentinels, if they need one"`
+	ClusterAddrs     []string      `toml:"cluster_addrs" env:"REDIS_CLUSTER_ADDRS" help:"host:port of some Redis Cluster nodes; when set, keys are routed to their node and url's host is ignored"`
+	TLSCAFile        string        `toml:"tls_ca_file" env:"REDIS_TLS_CA_FILE" help:"PEM CA certificates for rediss:// servers, instead of the system's"`
+	MaxActive        int           `toml:"max_active" env:"REDIS_MAX_ACTIVE" help:"most open connections, per node in a cluster, 0 for no limit"`
+	MaxIdle          int           `toml:"max_idle" env:"REDIS_MAX_IDLE" help:"most idle connections kept open"`
+	IdleTimeout      time.Duration `toml:"idle_timeout" env:"REDIS_IDLE_TIMEOUT" help:"close connections idle for longer than this"`
+	ConnectTimeout   time.Duration `toml:"connect_timeout" env:"REDIS_CONNECT_TIMEOUT" help:"how long to wait when connecting, including TLS and AUTH"`
+	ReadTimeout      time.Duration `toml:"read_timeout" env:"REDIS_READ_TIMEOUT" help:"how long to wait for a reply"`
+	WriteTimeout     time.Duration `toml:"write_timeout" env:"REDIS_WRITE_TIMEOUT" help:"how long to wait when sending a command"`
+	BreakerFailures  int           `toml:"breaker_failures" env:"REDIS_BREAKER_FAILURES" help:"connection failures in a row before Redis is skipped and scores aren't cached, 0 to always try"`
+	BreakerCooldown  time.Duration `toml:"breaker_cooldown" env:"REDIS_BREAKER_COOLDOWN" help:"how long Redis is skipped before trying it again"`
+}
+
+type CacheConfig struct {
+	TTL time.Duration `toml:"ttl" env:"CACHE_TTL" help:"how long scores are cached"`
+}
+
+type HistoryConfig struct {
+	Retention  time.Duration `toml:"retention" env:"HISTORY_RETENTION" help:"how long score history is kept, 0 to keep it forever"`
+	MaxEntries int           `toml:"max_entries" env:"HISTORY_MAX_ENTRIES" help:"most history entries kept per URL or slug, dropping the oldest; 0 for no limit"`
+}
+
+type CORSConfig struct {
+	AllowOrigins     []string      `toml:"allow_origins" env:"CORS_ALLOW_ORIGINS" help:"origins allowed to make cross-origin requests: *, https://example.com or https://*.example.com"`
+	AllowMethods     []string      `toml:"allow_methods" env:"CORS_ALLOW_METHODS" help:"methods allowed in cross-origin requests"`
+	AllowHeaders     []string      `toml:"allow_headers" env:"CORS_ALLOW_HEADERS" help:"request headers allowed in cross-origin requests"`
+	ExposeHeaders    []string      `toml:"expose_headers" env:"CORS_EXPOSE_HEADERS" help:"response headers readable by cross-origin scripts"`
+	AllowCredentials bool          `toml:"allow_credentials" env:"CORS_ALLOW_CREDENTIALS" help:"allow cookies and credentials in cross-origin requests; needs explicit origins"`
+	MaxAge           time.Duration `toml:"max_age" env:"CORS_MAX_AGE" help:"how long browsers may cache preflight responses"`
+	APIKeyOrigins    []string      `toml:"api_key_origins" env:"CORS_API_KEY_ORIGINS" secret:"api_keys" help:"extra origins allowed per API key, each like key=https://a.example https://b.example"`
+}
+
+type RateLimitConfig struct {
+	RequestsPerMinute int  `toml:"requests_per_minute" env:"RATE_LIMIT_REQUESTS_PER_MINUTE" help:"requests each client can make a minute, by API key or IP address; 0 for no limit"`
+	Burst             int  `toml:"burst" env:"RATE_LIMIT_BURST" help:"requests a client can make at once before being limited"`
+	TrustForwardedFor bool `toml:"trust_forwarded_for" env:"RATE_LIMIT_TRUST_FORWARDED_FOR" help:"take client IP addresses from X-Forwarded-For, when behind a proxy like Heroku's router"`
+}
+
+type ScorerConfig struct {
+	Path string `toml:"path" env:"SCORER_PATH" help:"path to get_score.rb"`
+}
+
+type BadgesConfig struct {
+	ColorScale   string `toml:"color_scale" env:"COLOR_SCALE" help:"default color scale: default, strict, gradient or shields"`
+	Thresholds   string `toml:"thresholds" env:"COLOR_THRESHOLDS" help:"comma-separated score thresholds for the color scale"`
+	Colors       string `toml:"colors" env:"COLOR_COLORS" help:"comma-separated colors for the color scale"`
+	Interpolate  string `toml:"interpolate" env:"COLOR_INTERPOLATE" help:"blend between colors: true, false, or the color scale's default when empty"`
+	GradeCutoffs string `toml:"grade_cutoffs" env:"GRADE_CUTOFFS" help:"comma-separated lowest scores for grades A to D"`
+}
+
+type GitHubConfig struct {
+	Token  string `toml:"token" env:"GITHUB_TOKEN" secret:"true" help:"API token for fetching READMEs at a ref"`
+	APIURL string `toml:"api_url" env:"GITHUB_API_URL" help:"GitHub API URL"`
+}
+
+type TracingConfig struct {
+	OTLPEndpoint       string `toml:"otlp_endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT" help:"OTLP/HTTP collector URL; tracing is off when empty"`
+	OTLPTracesEndpoint string `toml:"otlp_traces_endpoint" env:"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT" help:"full OTLP/HTTP traces URL, overriding otlp_endpoint"`
+	ServiceName        string `toml:"service_name" env:"OTEL_SERVICE_NAME" help:"service name on exported spans"`
+}
+
+func DefaultConfig() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:            3000,
+			Env:             ENV_DEVELOPMENT,
+			ErrorMode:       DEFAULT_ERROR_MODE,
+			ShutdownTimeout: DEFAULT_SHUTDOWN_TIMEOUT,
+		},
+		TLS: TLSConfig{
+			ReloadInterval: time.Minute,
+			MinVersion:     "1.2",
+			HTTP2:          true,
+			AdminPaths:     []string{"/metrics"},
+		},
+		Redis: RedisConfig{
+			URL:             "redis://localhost:6379",
+			SentinelMaster:  "mymaster",
+			MaxActive:       10,
+			MaxIdle:         3,
+			IdleTimeout:     240 * time.Second,
+			ConnectTimeout:  2 * time.Second,
+			ReadTimeout:     time.Second,
+			WriteTimeout:    time.Second,
+			BreakerFailures: 5,
+			BreakerCooldown: 30 * time.Second,
+		},
+		Cache: CacheConfig{TTL: DEFAULT_CACHE_TTL},
+		History: HistoryConfig{
+			Retention:  DEFAULT_HISTORY_RETENTION,
+			MaxEntries: DEFAULT_HISTORY_MAX_ENTRIES,
+		},
+		CORS: CORSConfig{
+			AllowOrigins:  []string{"*"},
+			AllowMethods:  []string{"GET", "POST"},
+			AllowHeaders:  []string{"Accept", "Content-Type", API_KEY_HEADER, REQUEST_ID_HEADER, TRACEPARENT_HEADER},
+			ExposeHeaders: []string{"Content-Type", "Cache-Control", "Expires", "Etag", "Last-Modified", REQUEST_ID_HEADER},
+			MaxAge:        10 * time.Minute,
+		},
+		RateLimit: RateLimitConfig{Burst: 20},
+		Scorer:    ScorerConfig{Path: DEFAULT_SCORER_PATH},
+		GitHub:    GitHubConfig{APIURL: DEFAULT_GITHUB_API_URL},
+		Tracing: TracingConfig{
+			ServiceName: "readme-score-api",
+		},
+	}
+}
+
+// One setting, found by walking Config's sections
+type ConfigField struct {
+	Name   string
+	Env    []string
+	Help   string
+	Secret string
+	Value  reflect.Value
+}
+
+func (config *Config) Fields() []ConfigField {
+	fields := []ConfigField{}
+	sections := reflect.ValueOf(config).Elem()
+	for i := 0; i < sections.NumField(); i++ {
+		section_name := sections.Type().Field(i).Tag.Get("toml")
+		section := sections.Field(i)
+		for j := 0; j < section.NumField(); j++ {
+			field := section.Type().Field(j)
+			env := []string{}
+			if names := field.Tag.Get("env"); names != "" {
+				env = strings.Split(names, ",")
+			}
+			fields = append(fields, ConfigField{
+				Name:   section_name + "." + field.Tag.Get("toml"),
+				Env:    env,
+				Help:   field.Tag.Get("help"),
+				Secret: field.Tag.Get("secret"),
+				Value:  section.Field(j),
+			})
+		}
+	}
+	return fields
+}
+
+var duration_type = reflect.TypeOf(time.Duration(0))
+
+// Durations are Go durations like "90s" or a whole number of seconds
+func ParseConfigDuration(value string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// Sets a field from an environment variable or flag
+func (field ConfigField) SetString(value string) error {
+	switch {
+	case field.Value.Type() == duration_type:
+		duration, err := ParseConfigDuration(value)
+		if err != nil {
+			return err
+		}
+		field.Value.SetInt(int64(duration))
+	case field.Value.Kind() == reflect.String:
+		field.Value.SetString(value)
+	case field.Value.Kind() == reflect.Int:
+		number, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		field.Value.SetInt(int64(number))
+	case field.Value.Kind() == reflect.Bool:
+		boolean, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.Value.SetBool(boolean)
+	case field.Value.Kind() == reflect.Slice:
+		values := []string{}
+		for _, element := range strings.Split(value, ",") {
+			if element = strings.TrimSpace(element); element != "" {
+				values = append(values, element)
+			}
+		}
+		field.Value.Set(reflect.ValueOf(values))
+	}
+	return nil
+}
+
+// Sets a field from a parsed TOML value
+func (field ConfigField) SetTOML(value interface{}) error {
+	switch value := value.(type) {
+	case string:
+		if field.Value.Kind() == reflect.String || field.Value.Type() == duration_type {
+			return field.SetString(value)
+		}
+		if field.Value.Kind() == reflect.Slice {
+			return errors.New("expected an array of strings")
+		}
+	case int64:
+		if field.Value.Type() == duration_type {
+			field.Value.SetInt(value * int64(time.Second))
+			return nil
+		}
+		if field.Value.Kind() == reflect.Int {
+			field.Value.SetInt(value)
+			return nil
+		}
+	case bool:
+		if field.Value.Kind() == reflect.Bool {
+			field.Value.SetBool(value)
+			return nil
+		}
+		if field.Value.Kind() == reflect.String {
+			field.Value.SetString(strconv.FormatBool(value))
+			return nil
+		}
+	case []interface{}:
+		if field.Value.Kind() == reflect.Slice {
+			values := make([]string, len(value))
+			for i, element := range value {
+				string_element, ok := element.(string)
+				if !ok {
+					return errors.New("expected an array of strings")
+				}
+				values[i] = string_element
+			}
+			field.Value.Set(reflect.ValueOf(values))
+			return nil
+		}
+	}
+	return fmt.Errorf("unexpected value %v", value)
+}
+
+func (config *Config) LoadFile(path string) error {
+	document, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	tables, err := ParseTOML(string(document))
+	if err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+	fields := map[string]ConfigField{}
+	for _, field := range config.Fields() {
+		fields[field.Name] = field
+	}
+	for table, values := range tables {
+		for key, value := range values {
+			field, ok := fields[table+"."+key]
+			if !ok {
+				return fmt.Errorf("%s: unknown setting %s", path, strings.TrimPrefix(table+"."+key, "."))
+			}
+			if err := field.SetTOML(value); err != nil {
+				return fmt.Errorf("%s: %s: %s", path, field.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (config *Config) LoadEnv(getenv func(string) string) error {
+	for _, field := range config.Fields() {
+		for _, name := range field.Env {
+			if value := getenv(name); value != "" {
+				if err := field.SetString(value); err != nil {
+					return fmt.Errorf("%s: %s", name, err)
+				}
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// Reads --config, --print-config and a flag for every setting, then builds
+// the config from defaults, the file (--config or CONFIG_FILE), the
+// environment and the flags
+func LoadConfig(args []string, getenv func(string) string) (config *Config, print_config bool, err error) {
+	config = DefaultConfig()
+	flags := flag.NewFlagSet("readme-score-api", flag.ContinueOnError)
+	config_file := flags.String("config", getenv("CONFIG_FILE"), "TOML config file")
+	flags.BoolVar(&print_config, "print-config", false, "print the effective config, with secrets redacted, and exit")
+	flag_values := map[string]string{}
+	for _, field := range config.Fields() {
+		name := field.Name
+		flags.Func(name, field.Help, func(value string) error {
+			flag_values[name] = value
+			return nil
+		})
+	}
+	if err = flags.Parse(args); err != nil {
+		return nil, false, err
+	}
+
+	if *config_file != "" {
+		if err = config.LoadFile(*config_file); err != nil {
+			return nil, false, err
+		}
+	}
+	if err = config.LoadEnv(getenv); err != nil {
+		return nil, false, err
+	}
+	for _, field := range config.Fields() {
+		if value, ok := flag_values[field.Name]; ok {
+			if err = field.SetString(value); err != nil {
+				return nil, false, fmt.Errorf("--%s: %s", field.Name, err)
+			}
+		}
+	}
+	return config, print_config, config.Validate()
+}
+
+// Reports every invalid setting at once
+func (config *Config) Validate() error {
+	problems := []string{}
+	invalid := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if config.Server.Port < 1 || config.Server.Port > 65535 {
+		invalid("server.port must be between 1 and 65535")
+	}
+	if config.Server.Env != ENV_DEVELOPMENT && config.Server.Env != ENV_PRODUCTION && config.Server.Env != ENV_TEST {
+		invalid("server.env must be development, production or test")
+	}
+	if config.Server.ErrorMode != ERROR_MODE_PANIC && config.Server.ErrorMode != ERROR_MODE_IGNORE {
+		invalid("server.error_mode must be %s or %s", ERROR_MODE_PANIC, ERROR_MODE_IGNORE)
+	}
+	if config.Server.ShutdownTimeout < 0 {
+		invalid("server.shutdown_timeout can't be negative")
+	}
+	if (config.TLS.CertFile == "") != (config.TLS.KeyFile == "") {
+		invalid("tls.cert_file and tls.key_file must be set together")
+	}
+	if config.TLS.ClientCAFile != "" && config.TLS.CertFile == "" {
+		invalid("tls.client_ca_file needs tls.cert_file and tls.key_file")
+	}
+	if config.TLS.ReloadInterval < 0 {
+		invalid("tls.reload_interval can't be negative")
+	}
+	if _, ok := TLS_VERSIONS[config.TLS.MinVersion]; !ok {
+		invalid("tls.min_version must be 1.2 or 1.3")
+	}
+	redis_address, redis_options, err := ParseRedisURL(config.Redis.URL)
+	if err != nil {
+		invalid("redis.url: %s", err)
+	} else if redis_address == "" && len(config.Redis.SentinelAddrs) == 0 && len(config.Redis.ClusterAddrs) == 0 {
+		invalid("redis.url needs a host, unless redis.sentinel_addrs or redis.cluster_addrs is set")
+	}
+	if len(config.Redis.SentinelAddrs) > 0 && len(config.Redis.ClusterAddrs) > 0 {
+		invalid("redis.sentinel_addrs and redis.cluster_addrs can't both be set")
+	}
+	if len(config.Redis.SentinelAddrs) > 0 && config.Redis.SentinelMaster == "" {
+		invalid("redis.sentinel_master is required with redis.sentinel_addrs")
+	}
+	if len(config.Redis.ClusterAddrs) > 0 && redis_options.DB != 0 {
+		invalid("redis.url can't select a database with redis.cluster_addrs")
+	}
+	if config.Redis.TLSCAFile != "" && redis_options.TLS == nil {
+		invalid("redis.tls_ca_file needs a rediss:// redis.url")
+	}
+	if config.Redis.MaxActive < 0 || config.Redis.MaxIdle < 0 {
+		invalid("redis.max_active and redis.max_idle can't be negative")
+	}
+	if config.Redis.MaxActive > 0 && config.Redis.MaxIdle > config.Redis.MaxActive {
+		invalid("redis.max_idle can't be more than redis.max_active")
+	}
+	if config.Redis.ConnectTimeout < 0 || config.Redis.ReadTimeout < 0 || config.Redis.WriteTimeout < 0 {
+		invalid("redis timeouts can't be negative")
+	}
+	if config.Redis.BreakerFailures < 0 || config.Redis.BreakerCooldown < 0 {
+		invalid("redis.breaker_failures and redis.breaker_cooldown can't be negative")
+	}
+	if config.Cache.TTL < time.Second {
+		invalid("cache.ttl must be at least 1s")
+	}
+	if config.History.Retention < 0 || config.History.MaxEntries < 0 {
+		invalid("history.retention and history.max_entries can't be negative")
+	}
+	for _, method := range config.CORS.AllowMethods {
+		if method != strings.ToUpper(method) {
+			invalid("cors.allow_methods must be upper case, got %s", method)
+		}
+	}
+	for _, origin := range config.CORS.AllowOrigins {
+		if err := ValidCORSOrigin(origin); err != nil {
+			invalid("cors.allow_origins: %s", err)
+		}
+		if origin == "*" && config.CORS.AllowCredentials {
+			invalid("cors.allow_credentials can't be used with the * origin")
+		}
+	}
+	if _, err := ParseAPIKeyOrigins(config.CORS.APIKeyOrigins); err != nil {
+		invalid("cors.api_key_origins: %s", err)
+	}
+	if config.RateLimit.RequestsPerMinute < 0 {
+		invalid("rate_limit.requests_per_minute can't be negative")
+	}
+	if config.RateLimit.RequestsPerMinute > 0 && config.RateLimit.Burst < 1 {
+		invalid("rate_limit.burst must be at least 1")
+	}
+	if config.Scorer.Path == "" {
+		invalid("scorer.path is required")
+	}
+	if _, err := config.ColorScale(); err != nil {
+		invalid("badges: %s", err)
+	}
+	if config.Badges.Interpolate != "" && config.Badges.Interpolate != "true" && config.Badges.Interpolate != "false" {
+		invalid("badges.interpolate must be true or false")
+	}
+	if config.Badges.GradeCutoffs != "" {
+		if _, err := ParseGradeCutoffs(config.Badges.GradeCutoffs); err != nil {
+			invalid("badges.grade_cutoffs: %s", err)
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New("Invalid config: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func (config *Config) ColorScale() (ColorScale, error) {
+	settings := map[string]string{
+		"color_scale": config.Badges.ColorScale,
+		"thresholds":  config.Badges.Thresholds,
+		"colors":      config.Badges.Colors,
+		"interpolate": config.Badges.Interpolate,
+	}
+	return ParseColorScale(COLOR_SCALES["default"], func(name string) string {
+		return settings[name]
+	})
+}
+
+func (config *Config) GradeCutoffs() []float32 {
+	if cutoffs, err := ParseGradeCutoffs(config.Badges.GradeCutoffs); err == nil {
+		return cutoffs
+	}
+	return DEFAULT_GRADE_CUTOFFS
+}
+
+// The collector's traces URL, or "" when tracing is off
+func (config *Config) OTLPTracesURL() string {
+	if config.Tracing.OTLPTracesEndpoint != "" {
+		return config.Tracing.OTLPTracesEndpoint
+	}
+	if config.Tracing.OTLPEndpoint != "" {
+		return strings.TrimRight(config.Tracing.OTLPEndpoint, "/") + "/v1/traces"
+	}
+	return ""
+}
+
+// Sets the package-level settings used outside of Server
+func (config *Config) Apply() {
+	Env = config.Server.Env
+	ErrorMode = config.Server.ErrorMode
+	CacheTTL = config.Cache.TTL
+	ScorerPath = config.Scorer.Path
+	GithubToken = config.GitHub.Token
+	GithubAPIURL = strings.TrimRight(config.GitHub.APIURL, "/")
+	DefaultColorScale, _ = config.ColorScale()
+	DefaultGradeCutoffs = config.GradeCutoffs()
+}
+
+func RedactConfigValue(field ConfigField) interface{} {
+	value := field.Value.Interface()
+	switch field.Secret {
+	case "true":
+		if field.Value.String() != "" {
+			return "[redacted]"
+		}
+	case "api_keys":
+		redacted := []string{}
+		for _, entry := range field.Value.Interface().([]string) {
+			parts := strings.SplitN(entry, "=", 2)
+			redacted = append(redacted, "[redacted]="+parts[len(parts)-1])
+		}
+		return redacted
+	case "url":
+		if secret_url, err := url.Parse(field.Value.String()); err == nil && secret_url.User != nil {
+			if _, has_password := secret_url.User.Password(); has_password {
+				secret_url.User = url.UserPassword(secret_url.User.Username(), "redacted")
+				return secret_url.String()
+			}
+		}
+	}
+	if duration, ok := value.(time.Duration); ok {
+		return duration.String()
+	}
+	return value
+}
+
+// The effective config as TOML, with secrets redacted
+func (config *Config) Print() []byte {
+	var doc bytes.Buffer
+	doc.WriteString("# Effective configuration: defaults < config file < environment < flags\n")
+	section := ""
+	for _, field := range config.Fields() {
+		parts := strings.SplitN(field.Name, ".", 2)
+		if parts[0] != section {
+			section = parts[0]
+			fmt.Fprintf(&doc, "\n[%s]\n", section)
+		}
+		fmt.Fprintf(&doc, "%s = %s\n", parts[1], FormatTOMLValue(RedactConfigValue(field)))
+	}
+	return doc.Bytes()
+}
+
+// Exits when the config is invalid, or after printing it for --print-config
+func ConfigFromCommandLine() *Config {
+	config, print_config, err := LoadConfig(os.Args[1:], os.Getenv)
+	if err == flag.ErrHelp {
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if print_config {
+		os.Stdout.Write(config.Print())
+		os.Exit(0)
+	}
+	return config
+}
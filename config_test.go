@@ -0,0 +1,97 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// Each layer overrides the ones before it: defaults, the TOML file, the
+// environment, then flags
+func TestLoadConfigPrecedence(t *testing.T) {
+	port := func(config *Config) string { return strconv.Itoa(config.Server.Port) }
+	env := func(config *Config) string { return config.Server.Env }
+	error_mode := func(config *Config) string { return config.Server.ErrorMode }
+	redis_url := func(config *Config) string { return config.Redis.URL }
+
+	tests := []struct {
+		name     string
+		file     string
+		env      map[string]string
+		args     []string
+		setting  func(*Config) string
+		expected string
+	}{
+		{"default", "", nil, nil, port, "3000"},
+		{"file over default", "[server]\nport = 4000\n", nil, nil, port, "4000"},
+		{"env over file", "[server]\nport = 4000\n", map[string]string{"PORT": "5000"}, nil, port, "5000"},
+		{"flag over env", "[server]\nport = 4000\n", map[string]string{"PORT": "5000"}, []string{"--server.port=6000"}, port, "6000"},
+		{"flag over file", "[server]\nport = 4000\n", nil, []string{"--server.port=6000"}, port, "6000"},
+		{"env without file", "", map[string]string{"PORT": "5000"}, nil, port, "5000"},
+
+		{"default env", "", nil, nil, env, ENV_DEVELOPMENT},
+		{"MARTINI_ENV over file", "[server]\nenv = \"test\"\n", map[string]string{"MARTINI_ENV": "production"}, nil, env, ENV_PRODUCTION},
+		{"flag over MARTINI_ENV", "", map[string]string{"MARTINI_ENV": "production"}, []string{"--server.env=test"}, env, ENV_TEST},
+
+		{"default error mode", "", nil, nil, error_mode, DEFAULT_ERROR_MODE},
+		{"file error mode", "[server]\nerror_mode = \"ignore\"\n", nil, nil, error_mode, ERROR_MODE_IGNORE},
+		{"ERROR_MODE over file", "[server]\nerror_mode = \"ignore\"\n", map[string]string{"ERROR_MODE": "panic"}, nil, error_mode, ERROR_MODE_PANIC},
+
+		{"first env name wins", "", map[string]string{"REDIS_URL": "redis://a:6379", "REDISCLOUD_URL": "redis://b:6379"}, nil, redis_url, "redis://a:6379"},
+		{"fallback env name", "[redis]\nurl = \"redis://file:6379\"\n", map[string]string{"REDISCLOUD_URL": "redis://b:6379"}, nil, redis_url, "redis://b:6379"},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			args := test.args
+			if test.file != "" {
+				config_path := filepath.Join(t.TempDir(), "config.toml")
+				if err := ioutil.WriteFile(config_path, []byte(test.file), 0644); err != nil {
+					t.Fatal(err)
+				}
+				args = append([]string{"--config", config_path}, args...)
+			}
+			config, _, err := LoadConfig(args, func(name string) string { return test.env[name] })
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual := test.setting(config); actual != test.expected {
+				t.Errorf("Got %s, expected %s", actual, test.expected)
+			}
+		})
+	}
+}
+
+// --config is used over CONFIG_FILE
+func TestLoadConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	for name, port := range map[string]string{"env.toml": "4000", "flag.toml": "5000"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("[server]\nport = "+port+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	getenv := func(name string) string {
+		if name == "CONFIG_FILE" {
+			return filepath.Join(dir, "env.toml")
+		}
+		return ""
+	}
+
+	for _, test := range []struct {
+		args []string
+		port int
+	}{
+		{nil, 4000},
+		{[]string{"--config", filepath.Join(dir, "flag.toml")}, 5000},
+	} {
+		config, _, err := LoadConfig(test.args, getenv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.Server.Port != test.port {
+			t.Errorf("%v: got port %d, expected %d", test.args, config.Server.Port, test.port)
+		}
+	}
+}
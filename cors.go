@@ -0,0 +1,234 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// API keys are sent in this header, or the `api_key` query parameter, which
+// also works for preflight requests
+const API_KEY_HEADER = "X-API-Key"
+
+// The origins allowed to call the API from a browser; see CORSConfig
+type CORSPolicy struct {
+	Origins          []string
+	Methods          []string
+	Headers          []string
+	ExposeHeaders    []string
+	AllowCredentials bool
+	MaxAge           int
+	// Extra origins allowed for requests with each API key
+	APIKeyOrigins map[string][]string
+}
+
+// Entries look like "key=https://a.example https://b.example"
+func ParseAPIKeyOrigins(entries []string) (map[string][]string, error) {
+	api_key_origins := map[string][]string{}
+	for _, entry := range entries {
+		parts := strings.SplitN(entry, "=", 2)
+		api_key := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || api_key == "" {
+			return nil, errors.New("API key origins must look like key=https://example.com")
+		}
+		origins := strings.Fields(parts[1])
+		for _, origin := range origins {
+			if err := ValidCORSOrigin(origin); err != nil {
+				return nil, err
+			}
+		}
+		api_key_origins[api_key] = append(api_key_origins[api_key], origins...)
+	}
+	return api_key_origins, nil
+}
+
+// Origins are "*", a scheme and host like "https://example.com", or a
+// scheme and wildcard subdomain like "https://*.example.com"
+func ValidCORSOrigin(origin string) error {
+	if origin == "*" {
+		return nil
+	}
+	origin_url, err := url.Parse(strings.Replace(origin, "://*.", "://wildcard.", 1))
+	if err != nil || origin_url.Scheme == "" || origin_url.Host == "" || (origin_url.Path != "" && origin_url.Path != "/") {
+		return errors.New("Invalid CORS origin " + origin)
+	}
+	return nil
+}
+
+func NewCORSPolicy(config CORSConfig) (*CORSPolicy, error) {
+	api_key_origins, err := ParseAPIKeyOrigins(config.APIKeyOrigins)
+	if err != nil {
+		return nil, err
+	}
+	return &CORSPolicy{
+		Origins:          config.AllowOrigins,
+		Methods:          config.AllowMethods,
+		Headers:          config.AllowHeaders,
+		ExposeHeaders:    config.ExposeHeaders,
+		AllowCredentials: config.AllowCredentials,
+		MaxAge:           int(config.MaxAge.Seconds()),
+		APIKeyOrigins:    api_key_origins,
+	}, nil
+}
+
+func CORSOriginMatches(allowed string, origin string) bool {
+	allowed = strings.TrimRight(strings.ToLower(allowed), "/")
+	origin = strings.ToLower(origin)
+	if allowed == "*" || allowed == origin {
+		return true
+	}
+	if wildcard := strings.Index(allowed, "://*."); wildcard >= 0 {
+		scheme := allowed[:wildcard+3]
+		domain := allowed[wildcard+4:]
+		return strings.HasPrefix(origin, scheme) && strings.HasSuffix(origin, domain) && len(origin) > len(scheme)+len(domain)
+	}
+	return false
+}
+
+func AnyCORSOriginMatches(allowed []string, origin string) bool {
+	for _, allowed_origin := range allowed {
+		if CORSOriginMatches(allowed_origin, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func APIKeyForRequest(req *http.Request) string {
+	if api_key := req.Header.Get(API_KEY_HEADER); api_key != "" {
+		return api_key
+	}
+	return req.URL.Query().Get("api_key")
+}
+
+// Preflight requests can't send the API key header, so without an
+// `api_key` parameter any key's origins are allowed. The request that
+// follows is checked against its own key.
+func (policy *CORSPolicy) OriginAllowed(origin string, api_key string, preflight bool) bool {
+	if AnyCORSOriginMatches(policy.Origins, origin) {
+		return true
+	}
+	if api_key != "" {
+		return AnyCORSOriginMatches(policy.APIKeyOrigins[api_key], origin)
+	}
+	if preflight {
+		for _, origins := range policy.APIKeyOrigins {
+			if AnyCORSOriginMatches(origins, origin) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (policy *CORSPolicy) MethodAllowed(method string) bool {
+	for _, allowed := range policy.Methods {
+		if allowed == method {
+			return true
+		}
+	}
+	return false
+}
+
+// The requested headers that are allowed, or false if any isn't
+func (policy *CORSPolicy) AllowedHeaders(requested string) ([]string, bool) {
+	allowed := []string{}
+	for _, header := range strings.Split(requested, ",") {
+		header = strings.TrimSpace(header)
+		if header == "" {
+			continue
+		}
+		found := false
+		for _, allowed_header := range policy.Headers {
+			if strings.EqualFold(header, allowed_header) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, false
+		}
+		allowed = append(allowed, header)
+	}
+	return allowed, true
+}
+
+// The keys given extra origins, which are the only ones the API knows
+func (policy *CORSPolicy) APIKeys() []string {
+	api_keys := []string{}
+	for api_key := range policy.APIKeyOrigins {
+		api_keys = append(api_keys, api_key)
+	}
+	return api_keys
+}
+
+// When every origin gets the same response. "*" can't be used with
+// credentials, so otherwise the origin is echoed.
+func (policy *CORSPolicy) AllowsAnyOrigin() bool {
+	return !policy.AllowCredentials && len(policy.Origins) == 1 && policy.Origins[0] == "*"
+}
+
+func (policy *CORSPolicy) SetOriginHeaders(header http.Header, origin string) {
+	if policy.AllowsAnyOrigin() {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+	if policy.AllowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// Middleware answering preflight requests and adding CORS headers to
+// allowed cross-origin requests
+func (policy *CORSPolicy) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if !policy.Handle(res, req) {
+			next.ServeHTTP(res, req)
+		}
+	})
+}
+
+// Returns whether it responded to the request. Disallowed preflights get a
+// 403; other disallowed requests are served without CORS headers, so
+// browsers block the response.
+func (policy *CORSPolicy) Handle(res http.ResponseWriter, req *http.Request) (responded bool) {
+	origin := req.Header.Get("Origin")
+	requested_method := req.Header.Get("Access-Control-Request-Method")
+	preflight := req.Method == "OPTIONS" && requested_method != ""
+	if !policy.AllowsAnyOrigin() {
+		res.Header().Add("Vary", "Origin")
+	}
+	if origin == "" {
+		return false
+	}
+	if !preflight {
+		if policy.OriginAllowed(origin, APIKeyForRequest(req), false) {
+			policy.SetOriginHeaders(res.Header(), origin)
+			if len(policy.ExposeHeaders) > 0 {
+				res.Header().Set("Access-Control-Expose-Headers", strings.Join(policy.ExposeHeaders, ", "))
+			}
+		}
+		return false
+	}
+
+	res.Header().Add("Vary", "Access-Control-Request-Method")
+	res.Header().Add("Vary", "Access-Control-Request-Headers")
+	allowed_headers, headers_ok := policy.AllowedHeaders(req.Header.Get("Access-Control-Request-Headers"))
+	if !policy.OriginAllowed(origin, req.URL.Query().Get("api_key"), true) || !policy.MethodAllowed(requested_method) || !headers_ok {
+		res.WriteHeader(http.StatusForbidden)
+		return true
+	}
+	policy.SetOriginHeaders(res.Header(), origin)
+	res.Header().Set("Access-Control-Allow-Methods", strings.Join(policy.Methods, ", "))
+	if len(allowed_headers) > 0 {
+		res.Header().Set("Access-Control-Allow-Headers", strings.Join(allowed_headers, ", "))
+	}
+	if policy.MaxAge > 0 {
+		res.Header().Set("Access-Control-Max-Age", strconv.Itoa(policy.MaxAge))
+	}
+	res.WriteHeader(http.StatusNoContent)
+	return true
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCORSPolicy(t *testing.T) {
+	restricted := CORSConfig{
+		AllowOrigins:  []string{"https://app.example", "https://*.example.org"},
+		AllowMethods:  []string{"GET", "POST"},
+		AllowHeaders:  []string{"Content-Type", API_KEY_HEADER},
+		ExposeHeaders: []string{"Etag"},
+		MaxAge:        10 * time.Minute,
+		APIKeyOrigins: []string{"partner-key=https://partner.example"},
+	}
+	any_origin := DefaultConfig().CORS
+
+	tests := []struct {
+		name    string
+		config  CORSConfig
+		method  string
+		url     string
+		headers map[string]string
+		status  int
+		// Expected response headers; "" means the header must be missing
+		expected map[string]string
+	}{
+		{"allowed origin", restricted, "GET", "/score", map[string]string{"Origin": "https://app.example"}, 200,
+			map[string]string{"Access-Control-Allow-Origin": "https://app.example", "Access-Control-Expose-Headers": "Etag", "Vary": "Origin"}},
+		{"allowed subdomain", restricted, "GET", "/score", map[string]string{"Origin": "https://docs.example.org"}, 200,
+			map[string]string{"Access-Control-Allow-Origin": "https://docs.example.org", "Vary": "Origin"}},
+		{"disallowed origin", restricted, "GET", "/score", map[string]string{"Origin": "https://evil.example"}, 200,
+			map[string]string{"Access-Control-Allow-Origin": "", "Access-Control-Expose-Headers": "", "Vary": "Origin"}},
+		{"bare domain isn't a subdomain", restricted, "GET", "/score", map[string]string{"Origin": "https://example.org"}, 200,
+			map[string]string{"Access-Control-Allow-Origin": ""}},
+		{"API key origin", restricted, "GET", "/score", map[string]string{"Origin": "https://partner.example", API_KEY_HEADER: "partner-key"}, 200,
+			map[string]string{"Access-Control-Allow-Origin": "https://partner.example"}},
+		{"API key origin without the key", restricted, "GET", "/score", map[string]string{"Origin": "https://partner.example"}, 200,
+			map[string]string{"Access-Control-Allow-Origin": ""}},
+		{"same origin", restricted, "GET", "/score", nil, 200,
+			map[string]string{"Access-Control-Allow-Origin": "", "Vary": "Origin"}},
+
+		{"preflight", restricted, "OPTIONS", "/score", map[string]string{"Origin": "https://app.example", "Access-Control-Request-Method": "POST", "Access-Control-Request-Headers": "content-type"}, 204,
+			map[string]string{
+				"Access-Control-Allow-Origin":  "https://app.example",
+				"Access-Control-Allow-Methods": "GET, POST",
+				"Access-Control-Allow-Headers": "content-type",
+				"Access-Control-Max-Age":       "600",
+				"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+			}},
+		{"preflight with an API key origin", restricted, "OPTIONS", "/score?api_key=partner-key", map[string]string{"Origin": "https://partner.example", "Access-Control-Request-Method": "GET"}, 204,
+			map[string]string{"Access-Control-Allow-Origin": "https://partner.example"}},
+		{"preflight from a disallowed origin", restricted, "OPTIONS", "/score", map[string]string{"Origin": "https://evil.example", "Access-Control-Request-Method": "GET"}, 403,
+			map[string]string{"Access-Control-Allow-Origin": "", "Access-Control-Allow-Methods": ""}},
+		{"preflight for a disallowed method", restricted, "OPTIONS", "/score", map[string]string{"Origin": "https://app.example", "Access-Control-Request-Method": "DELETE"}, 403,
+			map[string]string{"Access-Control-Allow-Origin": ""}},
+		{"preflight for a disallowed header", restricted, "OPTIONS", "/score", map[string]string{"Origin": "https://app.example", "Access-Control-Request-Method": "GET", "Access-Control-Request-Headers": "X-Secret"}, 403,
+			map[string]string{"Access-Control-Allow-Origin": ""}},
+
+		{"any origin", any_origin, "GET", "/score", map[string]string{"Origin": "https://anywhere.example"}, 200,
+			map[string]string{"Access-Control-Allow-Origin": "*", "Vary": ""}},
+		{"any origin preflight", any_origin, "OPTIONS", "/score", map[string]string{"Origin": "https://anywhere.example", "Access-Control-Request-Method": "GET"}, 204,
+			map[string]string{"Access-Control-Allow-Origin": "*", "Vary": "Access-Control-Request-Method, Access-Control-Request-Headers"}},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			policy, err := NewCORSPolicy(test.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			handler := policy.Middleware(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {}))
+			req := httptest.NewRequest(test.method, test.url, nil)
+			for name, value := range test.headers {
+				req.Header.Set(name, value)
+			}
+			res := httptest.NewRecorder()
+			handler.ServeHTTP(res, req)
+
+			if res.Code != test.status {
+				t.Errorf("Got status %d, expected %d", res.Code, test.status)
+			}
+			for name, expected := range test.expected {
+				if actual := strings.Join(res.Header()[name], ", "); actual != expected {
+					t.Errorf("Got %s %q, expected %q", name, actual, expected)
+				}
+			}
+		})
+	}
+}
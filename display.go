@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/garyburd/redigo/redis"
+	"strconv"
+	"strings"
+)
+
+// Every URL or slug's latest score, for computing percentiles
+const SCORES_SEEN_KEY = "scores_seen_v1"
+
+// Percentiles of fewer scores than this would mislead, so the plain score
+// is shown instead
+const MIN_PERCENTILE_SAMPLES = 10
+
+var ErrTooFewScoresSeen = errors.New("Too few scores seen for a percentile")
+
+// Scores at or above each cutoff get A, B, C and D; anything lower gets F
+var GRADE_LETTERS = []string{"A", "B", "C", "D"}
+var DEFAULT_GRADE_CUTOFFS = []float32{90, 80, 70, 60}
+
+// Used when a request doesn't send `grades`; see BadgesConfig
+var DefaultGradeCutoffs = DEFAULT_GRADE_CUTOFFS
+
+// Cutoffs are comma-separated and must be decreasing, one per grade letter
+func ParseGradeCutoffs(value string) ([]float32, error) {
+	cutoffs, err := ParseThresholds(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(cutoffs) != len(GRADE_LETTERS) {
+		return nil, fmt.Errorf("Expected %d grade cutoffs, got %d", len(GRADE_LETTERS), len(cutoffs))
+	}
+	for i := 1; i < len(cutoffs); i++ {
+		if cutoffs[i] >= cutoffs[i-1] {
+			return nil, errors.New("Grade cutoffs must be decreasing")
+		}
+	}
+	return cutoffs, nil
+}
+
+func GradeForScore(value float32, cutoffs []float32) string {
+	if len(cutoffs) != len(GRADE_LETTERS) {
+		cutoffs = DEFAULT_GRADE_CUTOFFS
+	}
+	for i, cutoff := range cutoffs {
+		if value >= cutoff {
+			return GRADE_LETTERS[i]
+		}
+	}
+	return "F"
+}
+
+// 1st, 2nd, 3rd, 4th, ... 11th, 12th, 13th, ... 21st
+func Ordinal(number int) string {
+	suffix := "th"
+	if number%100 < 11 || number%100 > 13 {
+		switch number % 10 {
+		case 1:
+			suffix = "st"
+		case 2:
+			suffix = "nd"
+		case 3:
+			suffix = "rd"
+		}
+	}
+	return strconv.Itoa(number) + suffix
+}
+
+func (server *Server) RecordScoreSeen(url_or_slug string, score *Score) {
+	if _, err := server.Redis("ZADD", SCORES_SEEN_KEY, score.TotalScore, url_or_slug); err != nil && err != ErrRedisUnavailable {
+		Logf(context.Background(), "Could not record score for %s: %s", url_or_slug, err)
+	}
+}
+
+// The percentage of READMEs the service has seen that score at or below
+// value, or ErrTooFewScoresSeen until MIN_PERCENTILE_SAMPLES have been seen
+func (server *Server) PercentileForScore(value float32) (int, error) {
+	total, err := redis.Int(server.Redis("ZCARD", SCORES_SEEN_KEY))
+	if err != nil {
+		return 0, err
+	}
+	if total < MIN_PERCENTILE_SAMPLES {
+		return 0, ErrTooFewScoresSeen
+	}
+	at_or_below, err := redis.Int(server.Redis("ZCOUNT", SCORES_SEEN_KEY, "-inf", value))
+	if err != nil {
+		return 0, err
+	}
+	return at_or_below * 100 / total, nil
+}
+
+// Sets score.Display for the `display` option. Percentiles fall back to
+// the plain score if they can't be computed.
+func (server *Server) ApplyDisplay(score *Score, options ScoreOptions) {
+	if score == nil {
+		return
+	}
+	switch strings.ToLower(options.Display) {
+	case "grade":
+		score.Display = GradeForScore(score.TotalScore, options.GradeCutoffs)
+	case "percentile":
+		if percentile, err := server.PercentileForScore(score.TotalScore); err == nil {
+			score.Display = Ordinal(percentile)
+		} else if err != ErrTooFewScoresSeen {
+			Logf(context.Background(), "Could not compute percentile: %s", err)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+)
+
+// Answers ZCARD and ZCOUNT on the scores seen with fixed counts
+type ScoresSeenPool struct {
+	total       int64
+	at_or_below int64
+}
+
+func (pool ScoresSeenPool) Do(command string, args ...interface{}) (interface{}, error) {
+	if command == "ZCARD" {
+		return pool.total, nil
+	}
+	return pool.at_or_below, nil
+}
+
+func (pool ScoresSeenPool) ActiveCount() int { return 0 }
+func (pool ScoresSeenPool) Close() error     { return nil }
+
+func TestApplyDisplayPercentile(t *testing.T) {
+	tests := []struct {
+		name     string
+		pool     ScoresSeenPool
+		expected string
+	}{
+		{"no scores seen", ScoresSeenPool{0, 0}, ""},
+		{"too few scores seen", ScoresSeenPool{MIN_PERCENTILE_SAMPLES - 1, 3}, ""},
+		{"enough scores seen", ScoresSeenPool{20, 10}, "50th"},
+	}
+	for _, test := range tests {
+		server := &Server{Pool: test.pool}
+		score := &Score{TotalScore: 55}
+		server.ApplyDisplay(score, ScoreOptions{Display: "percentile"})
+		if score.Display != test.expected {
+			t.Errorf("%s: got display %q, expected %q", test.name, score.Display, test.expected)
+		}
+		if value := score.DisplayValue(); test.expected == "" && value != "55" {
+			t.Errorf("%s: got value %q, expected the plain score", test.name, value)
+		}
+	}
+}
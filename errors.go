@@ -0,0 +1,15 @@
+package main
+
+// Request errors either panic, so Recover responds with a 500, or are
+// ignored so handlers respond with an error badge. Heroku builds (the
+// `heroku` build tag) ignore them by default.
+const ERROR_MODE_PANIC = "panic"
+const ERROR_MODE_IGNORE = "ignore"
+
+var ErrorMode = DEFAULT_ERROR_MODE
+
+func HandleError(err error) {
+	if err != nil && ErrorMode == ERROR_MODE_PANIC {
+		panic(err)
+	}
+}
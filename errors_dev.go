@@ -1,9 +1,6 @@
+//go:build !heroku
 // +build !heroku
 
 package main
 
-func HandleError(err error) {
-	if err != nil {
-		panic(err)
-	}
-}
+const DEFAULT_ERROR_MODE = ERROR_MODE_PANIC
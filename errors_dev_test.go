@@ -0,0 +1,12 @@
+//go:build !heroku
+// +build !heroku
+
+package main
+
+import "testing"
+
+func TestDefaultErrorMode(t *testing.T) {
+	if config := DefaultConfig(); config.Server.ErrorMode != ERROR_MODE_PANIC {
+		t.Errorf("Got error mode %s, expected %s", config.Server.ErrorMode, ERROR_MODE_PANIC)
+	}
+}
@@ -1,6 +1,6 @@
+//go:build heroku
 // +build heroku
 
 package main
 
-func HandleError(err error) {
-}
+const DEFAULT_ERROR_MODE = ERROR_MODE_IGNORE
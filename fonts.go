@@ -0,0 +1,75 @@
+package main
+
+import (
+	"unicode"
+)
+
+// Advance widths of a font's printable ASCII characters, in thousandths of
+// an em, for laying out badge text without a font renderer.
+type FontMetrics struct {
+	Widths map[rune]float32
+	// Width of characters missing from Widths
+	Default float32
+}
+
+// Verdana, used by the shields-style badges
+var VERDANA = FontMetrics{
+	Default: 636,
+	Widths: map[rune]float32{
+		' ': 352, '!': 394, '"': 459, '#': 818, '$': 636, '%': 1076, '&': 727, '\'': 269,
+		'(': 454, ')': 454, '*': 636, '+': 818, ',': 364, '-': 454, '.': 364, '/': 454,
+		'0': 636, '1': 636, '2': 636, '3': 636, '4': 636, '5': 636, '6': 636, '7': 636,
+		'8': 636, '9': 636, ':': 454, ';': 454, '<': 818, '=': 818, '>': 818, '?': 545,
+		'@': 1000, 'A': 684, 'B': 686, 'C': 698, 'D': 771, 'E': 632, 'F': 575, 'G': 775,
+		'H': 751, 'I': 421, 'J': 455, 'K': 693, 'L': 557, 'M': 843, 'N': 748, 'O': 787,
+		'P': 603, 'Q': 787, 'R': 695, 'S': 684, 'T': 616, 'U': 732, 'V': 684, 'W': 989,
+		'X': 685, 'Y': 615, 'Z': 685, '[': 454, '\\': 454, ']': 454, '^': 818, '_': 636,
+		'`': 636, 'a': 601, 'b': 623, 'c': 521, 'd': 623, 'e': 596, 'f': 352, 'g': 623,
+		'h': 633, 'i': 274, 'j': 344, 'k': 592, 'l': 274, 'm': 973, 'n': 633, 'o': 607,
+		'p': 623, 'q': 623, 'r': 427, 's': 521, 't': 394, 'u': 633, 'v': 592, 'w': 818,
+		'x': 592, 'y': 592, 'z': 525, '{': 635, '|': 454, '}': 635, '~': 818,
+	},
+}
+
+// Helvetica, the first widely available font in the ScoreMe badge's stack
+var HELVETICA = FontMetrics{
+	Default: 556,
+	Widths: map[rune]float32{
+		' ': 278, '!': 278, '"': 355, '#': 556, '$': 556, '%': 889, '&': 667, '\'': 191,
+		'(': 333, ')': 333, '*': 389, '+': 584, ',': 278, '-': 333, '.': 278, '/': 278,
+		'0': 556, '1': 556, '2': 556, '3': 556, '4': 556, '5': 556, '6': 556, '7': 556,
+		'8': 556, '9': 556, ':': 278, ';': 278, '<': 584, '=': 584, '>': 584, '?': 556,
+		'@': 1015, 'A': 667, 'B': 667, 'C': 722, 'D': 722, 'E': 667, 'F': 611, 'G': 778,
+		'H': 722, 'I': 278, 'J': 500, 'K': 667, 'L': 556, 'M': 833, 'N': 722, 'O': 778,
+		'P': 667, 'Q': 778, 'R': 722, 'S': 667, 'T': 611, 'U': 722, 'V': 667, 'W': 944,
+		'X': 667, 'Y': 667, 'Z': 611, '[': 278, '\\': 278, ']': 278, '^': 469, '_': 556,
+		'`': 333, 'a': 556, 'b': 556, 'c': 500, 'd': 556, 'e': 556, 'f': 278, 'g': 556,
+		'h': 556, 'i': 222, 'j': 222, 'k': 500, 'l': 222, 'm': 833, 'n': 556, 'o': 556,
+		'p': 556, 'q': 556, 'r': 333, 's': 500, 't': 278, 'u': 556, 'v': 500, 'w': 722,
+		'x': 500, 'y': 500, 'z': 500, '{': 334, '|': 260, '}': 334, '~': 584,
+	},
+}
+
+// Full-width characters take a whole em and combining marks take no space;
+// anything else missing from the table gets the font's default width.
+func (font FontMetrics) RuneWidth(r rune) float32 {
+	if width, ok := font.Widths[r]; ok {
+		return width
+	}
+	if unicode.In(r, unicode.Mn, unicode.Me) {
+		return 0
+	}
+	if unicode.In(r, unicode.Han, unicode.Hangul, unicode.Hiragana, unicode.Katakana) || (r >= 0xFF01 && r <= 0xFF60) {
+		return 1000
+	}
+	return font.Default
+}
+
+// Width of text in pixels at the given font size
+func (font FontMetrics) TextWidth(text string, font_size float32) float32 {
+	var width float32
+	for _, r := range text {
+		width += font.RuneWidth(r)
+	}
+	return width * font_size / 1000
+}
@@ -5,13 +5,36 @@ require 'bundler'
 Bundler.require
 
 require 'readme-score'
+require 'redcarpet'
 require 'json'
 
-url_or_slug = ARGV[0]
-human_breakdown = ARGV[1].to_s == "true"
-document = ReadmeScore.document(url_or_slug)
+# When the API is tracing it passes TRACEPARENT, and exports the spans we
+# print before the score under its own span for this run
+def report_span(name, started, error, attributes)
+  return unless ENV['TRACEPARENT']
+  span = {name: name, start: started.to_f, end: Time.now.to_f, attributes: attributes}
+  span[:error] = error.message if error
+  puts("span: " + span.to_json)
+end
+
+if ARGV[0] == "--markdown"
+  markdown = STDIN.read
+  renderer = Redcarpet::Markdown.new(Redcarpet::Render::HTML, fenced_code_blocks: true, autolink: true, tables: true)
+  document = ReadmeScore::Document.new(renderer.render(markdown))
+else
+  url_or_slug = ARGV[0]
+  human_breakdown = ARGV[1].to_s == "true"
+  fetch_started = Time.now
+  begin
+    document = ReadmeScore.document(url_or_slug)
+  rescue => fetch_error
+    raise
+  ensure
+    report_span("README fetch", fetch_started, fetch_error, "url_or_slug" => url_or_slug)
+  end
+end
 score = document.score
 rep = {total_score: score.total_score}
 rep[:human_breakdown] = score.human_breakdown
 rep[:breakdown] = score.breakdown
-puts(rep.to_json)
\ No newline at end of file
+puts(rep.to_json)
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"github.com/garyburd/redigo/redis"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How long a scorer self-test result is reused, so frequent readiness probes
+// don't each start Ruby
+const SCORER_SELF_TEST_TTL = 60 * time.Second
+
+// Scored offline with `get_score.rb --markdown`; it needs no network access
+const SCORER_SELF_TEST_MARKDOWN = "# Self test\n\nA README for checking the scorer runs.\n\n## Usage\n\n```sh\n$ readme-score\n```\n"
+
+type HealthCheck struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// For cached checks, when the check actually ran
+	CheckedAt string `json:"checked_at,omitempty"`
+}
+
+type HealthResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]HealthCheck `json:"checks,omitempty"`
+}
+
+func HealthCheckForError(err error) HealthCheck {
+	if err != nil {
+		return HealthCheck{Status: "fail", Error: err.Error()}
+	}
+	return HealthCheck{Status: "ok"}
+}
+
+type ScorerSelfTest struct {
+	lock       sync.Mutex
+	checked_at time.Time
+	err        error
+}
+
+var ScorerHealth = &ScorerSelfTest{}
+
+// Scores SCORER_SELF_TEST_MARKDOWN, reusing the last result (passing or not)
+// for SCORER_SELF_TEST_TTL
+func (self_test *ScorerSelfTest) Check() HealthCheck {
+	self_test.lock.Lock()
+	defer self_test.lock.Unlock()
+	if self_test.checked_at.IsZero() || time.Since(self_test.checked_at) > SCORER_SELF_TEST_TTL {
+		self_test.err = RunScorerSelfTest()
+		self_test.checked_at = time.Now()
+	}
+	check := HealthCheckForError(self_test.err)
+	check.CheckedAt = self_test.checked_at.UTC().Format(time.RFC3339)
+	return check
+}
+
+func RunScorerSelfTest() error {
+	scoreJson, err := RunScorer(context.Background(), strings.NewReader(SCORER_SELF_TEST_MARKDOWN), "--markdown")
+	if err != nil {
+		return err
+	}
+	score, err := ParseScoreJson(scoreJson)
+	if err != nil {
+		return err
+	}
+	if score.TotalScore <= 0 {
+		return errors.New("Scorer self test scored 0")
+	}
+	return nil
+}
+
+func (server *Server) CheckRedis() HealthCheck {
+	pong, err := redis.String(server.Redis("PING"))
+	if err == nil && pong != "PONG" {
+		err = errors.New("Unexpected PING reply: " + pong)
+	}
+	return HealthCheckForError(err)
+}
+
+func WriteHealthResponse(res http.ResponseWriter, health HealthResponse) {
+	res.Header().Set("Content-Type", "application/json")
+	res.Header().Set("Cache-Control", "no-cache")
+	if health.Status == "fail" {
+		res.WriteHeader(http.StatusServiceUnavailable)
+	}
+	res.Write(MarshalToJsonBytes(health))
+}
+
+// Liveness: the process is up and serving requests
+func (server *Server) GetHealthz(res http.ResponseWriter, req *http.Request) {
+	WriteHealthResponse(res, HealthResponse{Status: "ok"})
+}
+
+// Readiness: the scorer can score. Without Redis scores are still served,
+// just not cached, so a failing Redis check only makes the status
+// "degraded".
+func (server *Server) GetReadyz(res http.ResponseWriter, req *http.Request) {
+	health := HealthResponse{
+		Status: "ok",
+		Checks: map[string]HealthCheck{
+			"redis":  server.CheckRedis(),
+			"scorer": ScorerHealth.Check(),
+		},
+	}
+	if health.Checks["redis"].Status != "ok" {
+		health.Status = "degraded"
+	}
+	if health.Checks["scorer"].Status != "ok" {
+		health.Status = "fail"
+	}
+	WriteHealthResponse(res, health)
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/garyburd/redigo/redis"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// History is kept for a year, up to this many entries per URL or slug
+const DEFAULT_HISTORY_RETENTION = 365 * 24 * time.Hour
+const DEFAULT_HISTORY_MAX_ENTRIES = 1000
+
+// Dimensions of the sparkline drawn in templates/sparkline.svg
+const SPARKLINE_WIDTH = 40
+const SPARKLINE_HEIGHT = 14
+
+type HistoryEntry struct {
+	Time  int64   `json:"time"`
+	Score float32 `json:"score"`
+	SHA   string  `json:"sha,omitempty"`
+}
+
+type HistoryResponse struct {
+	URL      string         `json:"url"`
+	From     int64          `json:"from"`
+	To       int64          `json:"to"`
+	Interval int64          `json:"interval,omitempty"`
+	History  []HistoryEntry `json:"history"`
+}
+
+type SparklineSVG struct {
+	Title     string
+	Value     string
+	Color     string
+	TextColor string
+	Points    string
+}
+
+// Anything that can keep every score computed for a URL or slug.
+type HistoryStore interface {
+	Record(url_or_slug string, entry HistoryEntry) error
+	// Returns the entries with from <= Time <= to, oldest first
+	Range(url_or_slug string, from int64, to int64) ([]HistoryEntry, error)
+}
+
+// Keeps history in a Redis sorted set per URL or slug, scored by timestamp.
+// Entries older than Retention, and the oldest beyond MaxEntries, are
+// removed as new ones are recorded; either is unlimited when 0.
+type RedisHistoryStore struct {
+	Server     *Server
+	Retention  time.Duration
+	MaxEntries int
+}
+
+func HistoryKeyForUrlOrSlug(url_or_slug string) string {
+	return "history_v1:" + url_or_slug
+}
+
+func (store *RedisHistoryStore) Record(url_or_slug string, entry HistoryEntry) error {
+	key := HistoryKeyForUrlOrSlug(url_or_slug)
+	member, err := json.Marshal(entry)
+	if err == nil {
+		_, err = store.Server.Redis("ZADD", key, entry.Time, member)
+	}
+	if err == nil && store.Retention > 0 {
+		retention := int64(store.Retention / time.Second)
+		_, err = store.Server.Redis("ZREMRANGEBYSCORE", key, "-inf", "("+strconv.FormatInt(entry.Time-retention, 10))
+		if err == nil {
+			// Histories nobody has scored for a whole retention period go too
+			_, err = store.Server.Redis("EXPIRE", key, retention)
+		}
+	}
+	if err == nil && store.MaxEntries > 0 {
+		_, err = store.Server.Redis("ZREMRANGEBYRANK", key, 0, -store.MaxEntries-1)
+	}
+	return err
+}
+
+func (store *RedisHistoryStore) Range(url_or_slug string, from int64, to int64) ([]HistoryEntry, error) {
+	members, err := redis.Strings(store.Server.Redis("ZRANGEBYSCORE", HistoryKeyForUrlOrSlug(url_or_slug), from, to))
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]HistoryEntry, 0, len(members))
+	for _, member := range members {
+		entry := HistoryEntry{}
+		if err = json.Unmarshal([]byte(member), &entry); err == nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func (server *Server) RecordScoreHistory(url_or_slug string, score *Score, sha string) {
+	if server.History == nil || score == nil {
+		return
+	}
+	entry := HistoryEntry{
+		Time:  time.Now().Unix(),
+		Score: score.TotalScore,
+		SHA:   sha,
+	}
+	if err := server.History.Record(url_or_slug, entry); err != nil && err != ErrRedisUnavailable {
+		Logf(context.Background(), "Could not record history for %s: %s", url_or_slug, err)
+	}
+}
+
+// Averages entries into buckets of `interval` seconds starting at `from`.
+// Each bucket keeps the time it starts at and the SHA of its latest entry.
+func DownsampleHistory(entries []HistoryEntry, from int64, interval int64) []HistoryEntry {
+	if interval <= 0 || len(entries) == 0 {
+		return entries
+	}
+	downsampled := []HistoryEntry{}
+	var total float32
+	var count int
+	for i, entry := range entries {
+		bucket := from + (entry.Time-from)/interval*interval
+		total += entry.Score
+		count++
+		if i == len(entries)-1 || from+(entries[i+1].Time-from)/interval*interval != bucket {
+			downsampled = append(downsampled, HistoryEntry{
+				Time:  bucket,
+				Score: total / float32(count),
+				SHA:   entry.SHA,
+			})
+			total = 0
+			count = 0
+		}
+	}
+	return downsampled
+}
+
+// Accepts unix seconds or RFC3339 timestamps
+func ParseHistoryTime(value string, fallback int64) (int64, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return seconds, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return 0, HistoryQueryError("Could not parse time " + value)
+	}
+	return parsed.Unix(), nil
+}
+
+// Accepts seconds or a duration like "24h", of at least a second
+func ParseHistoryInterval(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		duration, duration_err := time.ParseDuration(value)
+		if duration_err != nil {
+			return 0, HistoryQueryError("Could not parse interval " + value)
+		}
+		seconds = int64(duration / time.Second)
+	}
+	if seconds <= 0 {
+		return 0, HistoryQueryError("Interval must be at least a second, not " + value)
+	}
+	return seconds, nil
+}
+
+// The number of buckets to downsample into, if any
+func ParseHistoryPoints(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	points, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || points <= 0 {
+		return 0, HistoryQueryError("Points must be a positive number, not " + value)
+	}
+	return points, nil
+}
+
+// A bad `from`, `to`, `interval` or `points`, answered with a 400 Bad
+// Request
+type HistoryQueryError string
+
+func (err HistoryQueryError) Error() string {
+	return string(err)
+}
+
+func SparklinePoints(entries []HistoryEntry) string {
+	if len(entries) == 0 {
+		return ""
+	}
+	points := make([]string, len(entries))
+	for i, entry := range entries {
+		x := float32(SPARKLINE_WIDTH)
+		if len(entries) > 1 {
+			x = float32(SPARKLINE_WIDTH*i) / float32(len(entries)-1)
+		}
+		score := entry.Score
+		if score < 0 {
+			score = 0
+		} else if score > 100 {
+			score = 100
+		}
+		y := SPARKLINE_HEIGHT - score/100*SPARKLINE_HEIGHT
+		points[i] = fmt.Sprintf("%.1f,%.1f", x, y)
+	}
+	if len(entries) == 1 {
+		return "0.0," + strings.Split(points[0], ",")[1] + " " + points[0]
+	}
+	return strings.Join(points, " ")
+}
+
+func HistoryAsSparklineTemplate(entries []HistoryEntry, scale ColorScale, url_or_slug string) SparklineSVG {
+	if len(entries) == 0 {
+		return SparklineSVG{
+			Title:     BadgeTitle(BADGE_LABEL+" history", "none", url_or_slug),
+			Value:     "?",
+			Color:     "#838383",
+			TextColor: ContrastingTextColor("#838383"),
+		}
+	}
+	latest := Score{TotalScore: entries[len(entries)-1].Score}
+	first := Score{TotalScore: entries[0].Score}
+	color := latest.AsColorWithScale(scale)
+	return SparklineSVG{
+		Title:     BadgeTitle(BADGE_LABEL+" history", first.DisplayValue()+" to "+latest.DisplayValue(), url_or_slug),
+		Value:     strconv.Itoa(int(latest.TotalScore)),
+		Color:     color,
+		TextColor: ContrastingTextColor(color),
+		Points:    SparklinePoints(entries),
+	}
+}
+
+func GetHistoryAsSparklineSVG(sparkline_svg SparklineSVG) []byte {
+	doc, err := ExecuteTemplate("sparkline.svg", sparkline_svg)
+	HandleError(err)
+
+	return doc
+}
+
+func GetHistoryAsText(entries []HistoryEntry) []byte {
+	var doc bytes.Buffer
+	for _, entry := range entries {
+		fmt.Fprintf(&doc, "%d %d %s\n", entry.Time, int(entry.Score), entry.SHA)
+	}
+	return doc.Bytes()
+}
+
+func (server *Server) GetHistory(res http.ResponseWriter, req *http.Request, params Params) {
+	query_params := req.URL.Query()
+	format := params["format"]
+	SetContentTypeForFormat(res, format)
+	now := time.Now().Unix()
+	var entries []HistoryEntry
+	var from, to, interval, points int64
+	var err error
+
+	url_or_slug := strings.ToLower(query_params.Get("url"))
+	if url_or_slug == "" {
+		url_or_slug = strings.ToLower(query_params.Get("github"))
+	}
+	if url_or_slug == "" {
+		err = errors.New("No value for :url or :github query parameter")
+	}
+	// Nothing older than the retention period is kept
+	var oldest int64
+	if retention := server.Config.History.Retention; retention > 0 {
+		oldest = now - int64(retention/time.Second)
+	}
+	if err == nil {
+		from, err = ParseHistoryTime(query_params.Get("from"), oldest)
+	}
+	if err == nil {
+		to, err = ParseHistoryTime(query_params.Get("to"), now)
+	}
+	if err == nil {
+		interval, err = ParseHistoryInterval(query_params.Get("interval"))
+	}
+	if err == nil && interval == 0 {
+		points, err = ParseHistoryPoints(query_params.Get("points"))
+	}
+	if err == nil && to < from {
+		err = HistoryQueryError("The range ends before it starts")
+	}
+	if _, bad_query := err.(HistoryQueryError); bad_query {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err == nil {
+		entries, err = server.History.Range(url_or_slug, from, to)
+	}
+	// Without a `from`, the points are spread from the oldest entry rather
+	// than the start of the retention period
+	if err == nil && points > 0 {
+		if query_params.Get("from") == "" && len(entries) > 0 {
+			from = entries[0].Time
+		}
+		interval = (to-from)/points + 1
+	}
+	HandleError(err)
+
+	if err != nil {
+		if format == "svg" {
+			WriteSVGWithETag(res, GetHistoryAsSparklineSVG(HistoryAsSparklineTemplate(nil, ColorScale{}, url_or_slug)))
+		} else if format == "txt" {
+			res.Write([]byte("error"))
+		} else {
+			res.Write(GetScoreErrorAsJson(url_or_slug))
+		}
+		return
+	}
+
+	entries = DownsampleHistory(entries, from, interval)
+	if format == "svg" {
+		WriteSVGWithETag(res, GetHistoryAsSparklineSVG(HistoryAsSparklineTemplate(entries, ColorScaleFromQuery(query_params), url_or_slug)))
+	} else if format == "txt" {
+		res.Write(GetHistoryAsText(entries))
+	} else {
+		res.Write(MarshalToJsonBytes(&HistoryResponse{
+			URL:      url_or_slug,
+			From:     from,
+			To:       to,
+			Interval: interval,
+			History:  entries,
+		}))
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Keeps entries in memory, in the order they're recorded
+type MemoryHistoryStore []HistoryEntry
+
+func (store *MemoryHistoryStore) Record(url_or_slug string, entry HistoryEntry) error {
+	*store = append(*store, entry)
+	return nil
+}
+
+func (store *MemoryHistoryStore) Range(url_or_slug string, from int64, to int64) ([]HistoryEntry, error) {
+	entries := []HistoryEntry{}
+	for _, entry := range *store {
+		if entry.Time >= from && entry.Time <= to {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func GetTestHistory(server *Server, query string) *httptest.ResponseRecorder {
+	res := httptest.NewRecorder()
+	server.GetHistory(res, httptest.NewRequest("GET", "/history.json?url=rails/rails&"+query, nil), Params{"format": "json"})
+	return res
+}
+
+// Four days of entries every six hours
+func NewTestHistoryServer() (*Server, int64) {
+	store := &MemoryHistoryStore{}
+	now := time.Now().Unix()
+	for hours := int64(96); hours >= 0; hours -= 6 {
+		store.Record("rails/rails", HistoryEntry{Time: now - hours*3600, Score: float32(100 - hours)})
+	}
+	return &Server{Config: DefaultConfig(), History: store}, now
+}
+
+func TestGetHistoryPoints(t *testing.T) {
+	server, now := NewTestHistoryServer()
+	for _, points := range []int{1, 2, 4} {
+		res := GetTestHistory(server, "points="+strconv.Itoa(points))
+		history := HistoryResponse{}
+		if err := json.Unmarshal(res.Body.Bytes(), &history); err != nil {
+			t.Fatalf("points=%d: %s in %s", points, err, res.Body.String())
+		}
+		if len(history.History) != points {
+			t.Errorf("points=%d: got %d buckets: %+v", points, len(history.History), history.History)
+		}
+		if history.From != now-96*3600 {
+			t.Errorf("points=%d: buckets start at %d, expected the oldest entry at %d", points, history.From, now-96*3600)
+		}
+	}
+}
+
+func TestGetHistoryDefaultsToRetentionPeriod(t *testing.T) {
+	server, now := NewTestHistoryServer()
+	history := HistoryResponse{}
+	json.Unmarshal(GetTestHistory(server, "").Body.Bytes(), &history)
+	if expected := now - int64(DEFAULT_HISTORY_RETENTION/time.Second); history.From < expected || history.From > expected+1 {
+		t.Errorf("Got from %d, expected %d", history.From, expected)
+	}
+	if len(history.History) != 17 {
+		t.Errorf("Got %d entries, expected 17", len(history.History))
+	}
+}
+
+func TestGetHistoryRejectsBadQueries(t *testing.T) {
+	server, _ := NewTestHistoryServer()
+	for _, query := range []string{"interval=0", "interval=-1h", "interval=soon", "points=0", "points=-3", "from=2&to=1", "to=yesterday"} {
+		if res := GetTestHistory(server, query); res.Code != 400 {
+			t.Errorf("%s: got %d, expected 400", query, res.Code)
+		}
+	}
+}
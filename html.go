@@ -0,0 +1,62 @@
+package main
+
+import (
+	"sort"
+)
+
+type ScoreHTML struct {
+	URL         string
+	Score       string
+	Color       string
+	Breakdown   []BreakdownRow
+	Suggestions []Suggestion
+	Error       string
+}
+
+type BreakdownRow struct {
+	Key    string
+	Points float32
+	Max    float32
+}
+
+func (score Score) BreakdownRows() []BreakdownRow {
+	rows := []BreakdownRow{}
+	for key, values := range score.HumanBreakdown {
+		row := BreakdownRow{Key: key}
+		if len(values) > 0 {
+			row.Points = values[0]
+		}
+		if len(values) > 1 {
+			row.Max = values[1]
+		}
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].Key < rows[j].Key
+	})
+	return rows
+}
+
+func GetScoreHTML(score_html ScoreHTML) []byte {
+	doc, err := ExecuteTemplate("score.html", score_html)
+	HandleError(err)
+
+	return doc
+}
+
+func GetScoreResponseAsHTML(score Score, url_or_slug string, options ScoreOptions) []byte {
+	return GetScoreHTML(ScoreHTML{
+		URL:         url_or_slug,
+		Score:       score.DisplayValue(),
+		Color:       score.AsColorWithScale(options.ColorScale),
+		Breakdown:   score.BreakdownRows(),
+		Suggestions: score.Suggestions(),
+	})
+}
+
+func GetScoreErrorAsHTML(url_or_slug string) []byte {
+	return GetScoreHTML(ScoreHTML{
+		URL:   url_or_slug,
+		Error: "Could not determine score for " + url_or_slug,
+	})
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const REQUEST_ID_HEADER = "X-Request-ID"
+
+// Propagated request ids longer than this are replaced with a new one
+const MAX_REQUEST_ID_LENGTH = 128
+
+// Every log line is a JSON object written here
+var LogOutput io.Writer = os.Stdout
+var log_lock sync.Mutex
+
+type LogFields map[string]interface{}
+
+// One line per request, written when it completes. Handlers fill in what
+// they know about the score through RequestLogFromContext.
+type RequestLog struct {
+	Time           string  `json:"time"`
+	RequestID      string  `json:"request_id"`
+	Method         string  `json:"method"`
+	Path           string  `json:"path"`
+	Format         string  `json:"format"`
+	UrlOrSlug      string  `json:"url_or_slug,omitempty"`
+	Cache          string  `json:"cache,omitempty"`
+	ScorerDuration float64 `json:"scorer_ms,omitempty"`
+	Status         int     `json:"status"`
+	Bytes          int     `json:"bytes"`
+	Duration       float64 `json:"duration_ms"`
+	lock           sync.Mutex
+}
+
+type request_log_key struct{}
+
+func ContextWithRequestLog(ctx context.Context, request_log *RequestLog) context.Context {
+	return context.WithValue(ctx, request_log_key{}, request_log)
+}
+
+// nil outside of a request, e.g. from the command line; RequestLog's
+// methods can be called on nil
+func RequestLogFromContext(ctx context.Context) *RequestLog {
+	request_log, _ := ctx.Value(request_log_key{}).(*RequestLog)
+	return request_log
+}
+
+func RequestIDFromContext(ctx context.Context) string {
+	if request_log := RequestLogFromContext(ctx); request_log != nil {
+		return request_log.RequestID
+	}
+	return ""
+}
+
+// Adds to a comma-separated field, since /compare scores twice
+func AppendLogValue(field *string, value string) {
+	if *field != "" {
+		*field += ","
+	}
+	*field += value
+}
+
+func (request_log *RequestLog) AddScore(url_or_slug string, cache string) {
+	if request_log == nil {
+		return
+	}
+	request_log.lock.Lock()
+	defer request_log.lock.Unlock()
+	AppendLogValue(&request_log.UrlOrSlug, url_or_slug)
+	AppendLogValue(&request_log.Cache, cache)
+}
+
+func (request_log *RequestLog) AddScorerDuration(duration time.Duration) {
+	if request_log == nil {
+		return
+	}
+	request_log.lock.Lock()
+	defer request_log.lock.Unlock()
+	request_log.ScorerDuration += DurationInMilliseconds(duration)
+}
+
+func DurationInMilliseconds(duration time.Duration) float64 {
+	return float64(duration.Microseconds()) / 1000
+}
+
+func WriteLogLine(line []byte) {
+	log_lock.Lock()
+	defer log_lock.Unlock()
+	LogOutput.Write(append(line, '\n'))
+}
+
+// Logs the fields with the time and, during a request, its id
+func LogJSON(ctx context.Context, fields LogFields) {
+	fields["time"] = time.Now().UTC().Format(time.RFC3339Nano)
+	if request_id := RequestIDFromContext(ctx); request_id != "" {
+		fields["request_id"] = request_id
+	}
+	WriteLogLine(MarshalToJsonBytes(fields))
+}
+
+func Logf(ctx context.Context, format string, args ...interface{}) {
+	LogJSON(ctx, LogFields{"message": fmt.Sprintf(format, args...)})
+}
+
+func GenerateRequestID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// Reuses the client's or proxy's id when it's short and printable
+func RequestIDForRequest(req *http.Request) string {
+	request_id := req.Header.Get(REQUEST_ID_HEADER)
+	if request_id == "" || len(request_id) > MAX_REQUEST_ID_LENGTH {
+		return GenerateRequestID()
+	}
+	for _, c := range request_id {
+		if c < '!' || c > '~' {
+			return GenerateRequestID()
+		}
+	}
+	return request_id
+}
+
+// Middleware logging each request as a JSON line. It must run outside of
+// Recover so panics are logged as 500s.
+func LogRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		started := time.Now()
+		request_log := &RequestLog{
+			RequestID: RequestIDForRequest(req),
+			Method:    req.Method,
+			Path:      req.URL.Path,
+			Format:    FormatForPath(req.URL.Path),
+		}
+		response := NewStatusWriter(res)
+		response.Header().Set(REQUEST_ID_HEADER, request_log.RequestID)
+
+		next.ServeHTTP(response, req.WithContext(ContextWithRequestLog(req.Context(), request_log)))
+
+		request_log.lock.Lock()
+		defer request_log.lock.Unlock()
+		request_log.Time = started.UTC().Format(time.RFC3339Nano)
+		request_log.Status = response.Status()
+		request_log.Bytes = response.Size()
+		request_log.Duration = DurationInMilliseconds(time.Since(started))
+		line, _ := json.Marshal(request_log)
+		WriteLogLine(line)
+	})
+}
+
+// Logs each line the scorer writes to stderr with the request's id
+type ScorerLogWriter struct {
+	ctx     context.Context
+	partial bytes.Buffer
+}
+
+func (writer *ScorerLogWriter) Write(output []byte) (int, error) {
+	writer.partial.Write(output)
+	for {
+		line, err := writer.partial.ReadString('\n')
+		if err != nil {
+			// Keep the incomplete line for the next write
+			writer.partial.WriteString(line)
+			break
+		}
+		writer.LogLine(line)
+	}
+	return len(output), nil
+}
+
+// Logs a last line that didn't end with a newline
+func (writer *ScorerLogWriter) Flush() {
+	if writer.partial.Len() > 0 {
+		writer.LogLine(writer.partial.String())
+		writer.partial.Reset()
+	}
+}
+
+func (writer *ScorerLogWriter) LogLine(line string) {
+	LogJSON(writer.ctx, LogFields{"scorer_stderr": strings.TrimRight(line, "\r\n")})
+}
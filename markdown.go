@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Largest Markdown document we're willing to score (1MB)
+const MAX_MARKDOWN_BYTES = 1 << 20
+
+type MarkdownRequest struct {
+	Markdown string `json:"markdown"`
+}
+
+func HashForMarkdown(markdown string) string {
+	hash := sha1.New()
+	io.WriteString(hash, markdown)
+	return fmt.Sprintf("%x", hash.Sum(nil))
+}
+
+func CacheKeyForMarkdownHash(hash string) string {
+	return "markdown_v1:" + hash
+}
+
+// Pulls the Markdown out of a POST body, which may be raw text/markdown,
+// JSON with a "markdown" field, or a form/multipart upload.
+func ReadMarkdownFromRequest(req *http.Request) (string, error) {
+	media_type, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+
+	switch media_type {
+	case "application/json":
+		markdown_req := MarkdownRequest{}
+		if err := json.NewDecoder(req.Body).Decode(&markdown_req); err != nil {
+			return "", err
+		}
+		return markdown_req.Markdown, nil
+	case "multipart/form-data":
+		if err := req.ParseMultipartForm(MAX_MARKDOWN_BYTES); err != nil {
+			return "", err
+		}
+		for _, field := range []string{"markdown", "file"} {
+			if file, _, err := req.FormFile(field); err == nil {
+				defer file.Close()
+				markdown_bytes, err := ioutil.ReadAll(file)
+				return string(markdown_bytes), err
+			}
+		}
+		return req.FormValue("markdown"), nil
+	case "application/x-www-form-urlencoded":
+		return req.PostFormValue("markdown"), nil
+	}
+
+	markdown_bytes, err := ioutil.ReadAll(req.Body)
+	return string(markdown_bytes), err
+}
+
+func (server *Server) GetScoreForMarkdown(ctx context.Context, markdown string, force bool) (*Score, error) {
+	var score *Score
+	var err error
+	hash := HashForMarkdown(markdown)
+	score, err = server.GetCachedScore(ctx, CacheKeyForMarkdownHash(hash))
+	RequestLogFromContext(ctx).AddScore("markdown:"+hash, Metrics.RecordCacheLookup(score, force))
+	if err != nil || force {
+		var scoreJson string
+		if scoreJson, err = RunScorer(ctx, strings.NewReader(markdown), "--markdown"); err == nil {
+			server.CacheScore(ctx, scoreJson, CacheKeyForMarkdownHash(hash))
+			score, err = ParseScoreJson(scoreJson)
+		}
+	}
+
+	return score, err
+}
+
+func (server *Server) PostScore(res http.ResponseWriter, req *http.Request, params Params) {
+	query_params := req.URL.Query()
+	options := ScoreOptionsFromQuery(query_params)
+	_, force := query_params["force"]
+	format := params["format"]
+	SetContentTypeForFormat(res, format)
+	var score *Score
+
+	req.Body = http.MaxBytesReader(res, req.Body, MAX_MARKDOWN_BYTES)
+	markdown, err := ReadMarkdownFromRequest(req)
+	if err == nil && strings.TrimSpace(markdown) == "" {
+		err = errors.New("No Markdown in request body")
+	}
+
+	url_or_slug := "markdown"
+	if err == nil {
+		url_or_slug = "markdown:" + HashForMarkdown(markdown)
+		score, err = server.GetScoreForMarkdown(req.Context(), markdown, force)
+	}
+	HandleError(err)
+	server.ApplyDisplay(score, options)
+
+	WriteScoreResponse(res, format, score, url_or_slug, options)
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Prefix for every exported metric name
+const METRICS_NAMESPACE = "readme_score"
+
+// Request formats counted by name; any other extension counts as "other"
+var METRICS_FORMATS = []string{"json", "html", "svg", "png", "txt", "shields", "md"}
+
+// Upper bounds, in seconds, of the scorer duration histogram buckets
+var SCORER_DURATION_BUCKETS = []float64{0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60}
+
+// A counter split by one or more labels, e.g. requests by format and status
+type CounterVec struct {
+	Name   string
+	Help   string
+	Labels []string
+	lock   sync.Mutex
+	values map[string]float64
+}
+
+func NewCounterVec(name string, help string, labels ...string) *CounterVec {
+	return &CounterVec{
+		Name:   name,
+		Help:   help,
+		Labels: labels,
+		values: map[string]float64{},
+	}
+}
+
+// Label values are given in the same order as Labels
+func (counter *CounterVec) Inc(label_values ...string) {
+	key := strings.Join(label_values, "\x00")
+	counter.lock.Lock()
+	counter.values[key]++
+	counter.lock.Unlock()
+}
+
+func (counter *CounterVec) WriteTo(buffer *bytes.Buffer) {
+	WriteMetricHeader(buffer, counter.Name, counter.Help, "counter")
+	counter.lock.Lock()
+	defer counter.lock.Unlock()
+	keys := make([]string, 0, len(counter.values))
+	for key := range counter.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		labels := FormatMetricLabels(counter.Labels, strings.Split(key, "\x00"))
+		fmt.Fprintf(buffer, "%s%s %s\n", counter.Name, labels, FormatMetricValue(counter.values[key]))
+	}
+}
+
+// A cumulative histogram, as Prometheus expects it
+type Histogram struct {
+	Name    string
+	Help    string
+	Buckets []float64
+	lock    sync.Mutex
+	counts  []uint64
+	count   uint64
+	sum     float64
+}
+
+func NewHistogram(name string, help string, buckets []float64) *Histogram {
+	return &Histogram{
+		Name:    name,
+		Help:    help,
+		Buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+}
+
+func (histogram *Histogram) Observe(value float64) {
+	histogram.lock.Lock()
+	defer histogram.lock.Unlock()
+	for i, bound := range histogram.Buckets {
+		if value <= bound {
+			histogram.counts[i]++
+		}
+	}
+	histogram.count++
+	histogram.sum += value
+}
+
+func (histogram *Histogram) WriteTo(buffer *bytes.Buffer) {
+	WriteMetricHeader(buffer, histogram.Name, histogram.Help, "histogram")
+	histogram.lock.Lock()
+	defer histogram.lock.Unlock()
+	for i, bound := range histogram.Buckets {
+		fmt.Fprintf(buffer, "%s_bucket{le=\"%s\"} %d\n", histogram.Name, FormatMetricValue(bound), histogram.counts[i])
+	}
+	fmt.Fprintf(buffer, "%s_bucket{le=\"+Inf\"} %d\n", histogram.Name, histogram.count)
+	fmt.Fprintf(buffer, "%s_sum %s\n", histogram.Name, FormatMetricValue(histogram.sum))
+	fmt.Fprintf(buffer, "%s_count %d\n", histogram.Name, histogram.count)
+}
+
+type ServerMetrics struct {
+	Requests        *CounterVec
+	CacheLookups    *CounterVec
+	ScorerDuration  *Histogram
+	ScorerFailures  *CounterVec
+	ScoringInFlight int64
+	// Redis connections currently borrowed from the pool; the rest of the
+	// pool's active connections are idle
+	RedisInUse int64
+}
+
+var Metrics = NewServerMetrics()
+
+func NewServerMetrics() *ServerMetrics {
+	return &ServerMetrics{
+		Requests: NewCounterVec(METRICS_NAMESPACE+"_requests_total",
+			"HTTP requests by response format and status code.", "format", "status"),
+		CacheLookups: NewCounterVec(METRICS_NAMESPACE+"_cache_lookups_total",
+			"Score cache lookups by result: hit, miss, or stale (cached but refreshed with force).", "result"),
+		ScorerDuration: NewHistogram(METRICS_NAMESPACE+"_scorer_duration_seconds",
+			"Time spent running the Ruby scorer.", SCORER_DURATION_BUCKETS),
+		ScorerFailures: NewCounterVec(METRICS_NAMESPACE+"_scorer_failures_total",
+			"Scorer runs that failed, by error class.", "class"),
+	}
+}
+
+// Called after each score cache lookup; `force` refreshes even a cached
+// score. Returns the result that was counted.
+func (metrics *ServerMetrics) RecordCacheLookup(cached *Score, force bool) string {
+	result := "hit"
+	if cached == nil {
+		result = "miss"
+	} else if force {
+		result = "stale"
+	}
+	metrics.CacheLookups.Inc(result)
+	return result
+}
+
+// Times one scorer run and keeps the in-flight gauge up to date. Call the
+// returned function with the run's error, if any, once it finishes.
+func (metrics *ServerMetrics) StartScoring() func(error) {
+	started := time.Now()
+	atomic.AddInt64(&metrics.ScoringInFlight, 1)
+	return func(err error) {
+		atomic.AddInt64(&metrics.ScoringInFlight, -1)
+		metrics.ScorerDuration.Observe(time.Since(started).Seconds())
+		if err != nil {
+			metrics.ScorerFailures.Inc(ScorerErrorClass(err))
+		}
+	}
+}
+
+// Groups scorer errors into a few classes so the label stays low-cardinality
+func ScorerErrorClass(err error) string {
+	switch err.(type) {
+	case *exec.ExitError:
+		return "exit"
+	case *exec.Error:
+		return "start"
+	case ScorerOutputError:
+		return "output"
+	}
+	return "other"
+}
+
+// The scorer ran but didn't print a score
+type ScorerOutputError string
+
+func (err ScorerOutputError) Error() string {
+	return string(err)
+}
+
+// Middleware counting every response by its format and status. The format
+// is the path's extension, so /score and /compare count as json.
+func (metrics *ServerMetrics) CountRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		response := NewStatusWriter(res)
+		next.ServeHTTP(response, req)
+		if req.URL.Path == "/metrics" {
+			return
+		}
+		metrics.Requests.Inc(FormatForPath(req.URL.Path), strconv.Itoa(response.Status()))
+	})
+}
+
+func FormatForPath(request_path string) string {
+	extension := path.Ext(request_path)
+	if extension == "" {
+		return "json"
+	}
+	for _, format := range METRICS_FORMATS {
+		if extension[1:] == format {
+			return format
+		}
+	}
+	return "other"
+}
+
+func (metrics *ServerMetrics) WriteTo(buffer *bytes.Buffer, server *Server) {
+	metrics.Requests.WriteTo(buffer)
+	metrics.CacheLookups.WriteTo(buffer)
+	metrics.ScorerDuration.WriteTo(buffer)
+	metrics.ScorerFailures.WriteTo(buffer)
+	WriteGauge(buffer, METRICS_NAMESPACE+"_scoring_in_flight",
+		"Scorer runs in progress.", float64(atomic.LoadInt64(&metrics.ScoringInFlight)))
+	if server.Pool != nil {
+		available := 1.0
+		if server.Breaker.Open() {
+			available = 0
+		}
+		WriteGauge(buffer, METRICS_NAMESPACE+"_redis_available",
+			"Whether Redis is used; 0 while the circuit breaker skips it after failures.", available)
+		active := server.Pool.ActiveCount()
+		in_use := int(atomic.LoadInt64(&metrics.RedisInUse))
+		idle := active - in_use
+		if idle < 0 {
+			idle = 0
+		}
+		WriteGauge(buffer, METRICS_NAMESPACE+"_redis_pool_active_connections",
+			"Open Redis connections in the pool, in use or idle.", float64(active))
+		WriteGauge(buffer, METRICS_NAMESPACE+"_redis_pool_idle_connections",
+			"Open Redis connections waiting in the pool.", float64(idle))
+	}
+}
+
+func WriteMetricHeader(buffer *bytes.Buffer, name string, help string, metric_type string) {
+	fmt.Fprintf(buffer, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metric_type)
+}
+
+func WriteGauge(buffer *bytes.Buffer, name string, help string, value float64) {
+	WriteMetricHeader(buffer, name, help, "gauge")
+	fmt.Fprintf(buffer, "%s %s\n", name, FormatMetricValue(value))
+}
+
+var METRIC_LABEL_ESCAPER = strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n")
+
+// e.g. {format="svg",status="200"}
+func FormatMetricLabels(names []string, values []string) string {
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		pairs[i] = name + "=\"" + METRIC_LABEL_ESCAPER.Replace(value) + "\""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func FormatMetricValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func (server *Server) GetMetrics(res http.ResponseWriter, req *http.Request) {
+	var buffer bytes.Buffer
+	Metrics.WriteTo(&buffer, server)
+	res.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	res.Write(buffer.Bytes())
+}
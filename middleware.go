@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+)
+
+// Wraps a handler to do something before and/or after it, or instead of it
+type Middleware func(http.Handler) http.Handler
+
+// The first middleware is the outermost, so it runs first and finishes last
+func Chain(handler http.Handler, middleware ...Middleware) http.Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+	return handler
+}
+
+// Records the status and size of the response for logging and metrics.
+// Middleware shares one StatusWriter per request, from NewStatusWriter.
+type StatusWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func NewStatusWriter(res http.ResponseWriter) *StatusWriter {
+	if status_writer, ok := res.(*StatusWriter); ok {
+		return status_writer
+	}
+	return &StatusWriter{ResponseWriter: res}
+}
+
+func (writer *StatusWriter) WriteHeader(status int) {
+	writer.ResponseWriter.WriteHeader(status)
+	writer.status = status
+}
+
+func (writer *StatusWriter) Write(body []byte) (int, error) {
+	if !writer.Written() {
+		writer.WriteHeader(http.StatusOK)
+	}
+	size, err := writer.ResponseWriter.Write(body)
+	writer.size += size
+	return size, err
+}
+
+// The status written, or 200 if the handler didn't write one
+func (writer *StatusWriter) Status() int {
+	if writer.status == 0 {
+		return http.StatusOK
+	}
+	return writer.status
+}
+
+func (writer *StatusWriter) Size() int {
+	return writer.size
+}
+
+func (writer *StatusWriter) Written() bool {
+	return writer.status != 0
+}
+
+func (writer *StatusWriter) Flush() {
+	if flusher, ok := writer.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (writer *StatusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := writer.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("The ResponseWriter doesn't support hijacking")
+	}
+	return hijacker.Hijack()
+}
+
+// For http.ResponseController
+func (writer *StatusWriter) Unwrap() http.ResponseWriter {
+	return writer.ResponseWriter
+}
+
+const PANIC_HTML = `<html>
+<head><title>PANIC: %s</title>
+<style type="text/css">
+html, body {
+	font-family: "Roboto", sans-serif;
+	color: #333333;
+	background-color: #ea5343;
+	margin: 0px;
+}
+h1 {
+	color: #d04526;
+	background-color: #ffffff;
+	padding: 20px;
+	border-bottom: 1px dashed #2b3848;
+}
+pre {
+	margin: 20px;
+	padding: 20px;
+	border: 2px solid #2b3848;
+	background-color: #ffffff;
+}
+</style>
+</head><body>
+<h1>PANIC</h1>
+<pre style="font-weight: bold;">%s</pre>
+<pre>%s</pre>
+</body>
+</html>`
+
+// Turns panics, like those from HandleError, into 500s. In development the
+// response shows the panic and stack trace.
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		defer func() {
+			if err := recover(); err != nil {
+				stack := debug.Stack()
+				Logf(req.Context(), "PANIC: %s\n%s", err, stack)
+				var body []byte
+				if Env == ENV_DEVELOPMENT {
+					res.Header().Set("Content-Type", "text/html")
+					body = []byte(fmt.Sprintf(PANIC_HTML, err, err, stack))
+				}
+				res.WriteHeader(http.StatusInternalServerError)
+				if body != nil {
+					res.Write(body)
+				}
+			}
+		}()
+		next.ServeHTTP(res, req)
+	})
+}
+
+// Serves GET and HEAD requests for files in `directory`, and index.html
+// for directories. Other requests are passed on.
+func ServeStatic(directory string) Middleware {
+	dir := http.Dir(directory)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			if req.Method != "GET" && req.Method != "HEAD" {
+				next.ServeHTTP(res, req)
+				return
+			}
+			file := req.URL.Path
+			f, err := dir.Open(file)
+			if err != nil {
+				next.ServeHTTP(res, req)
+				return
+			}
+			defer f.Close()
+			info, err := f.Stat()
+			if err != nil {
+				next.ServeHTTP(res, req)
+				return
+			}
+			if info.IsDir() {
+				if !strings.HasSuffix(req.URL.Path, "/") {
+					http.Redirect(res, req, req.URL.Path+"/", http.StatusFound)
+					return
+				}
+				file = filepath.Join(file, "index.html")
+				index, err := dir.Open(file)
+				if err != nil {
+					next.ServeHTTP(res, req)
+					return
+				}
+				defer index.Close()
+				if info, err = index.Stat(); err != nil || info.IsDir() {
+					next.ServeHTTP(res, req)
+					return
+				}
+				f = index
+			}
+			http.ServeContent(res, req, file, info.ModTime(), f)
+		})
+	}
+}
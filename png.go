@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"strings"
+)
+
+// Largest `scale` a PNG badge can be rendered at
+const MAX_PNG_SCALE = 4
+
+// Largest logo, in pixels each way, DrawLogo will decode. Logos are drawn
+// 14px square, and decoding a bigger one could take a lot of memory.
+const MAX_PNG_LOGO_SIZE = 256
+
+// Glyphs are 5 columns wide plus a column of spacing
+const PNG_GLYPH_WIDTH = 6
+
+// A 5x8 bitmap font for printable ASCII. Each byte is a column, with the
+// least significant bit at the top; the 8th row is for descenders.
+var PNG_FONT = map[rune][5]byte{
+	' ':  {0x00, 0x00, 0x00, 0x00, 0x00},
+	'!':  {0x00, 0x00, 0x5F, 0x00, 0x00},
+	'"':  {0x00, 0x07, 0x00, 0x07, 0x00},
+	'#':  {0x14, 0x7F, 0x14, 0x7F, 0x14},
+	'$':  {0x24, 0x2A, 0x7F, 0x2A, 0x12},
+	'%':  {0x23, 0x13, 0x08, 0x64, 0x62},
+	'&':  {0x36, 0x49, 0x56, 0x20, 0x50},
+	'\'': {0x00, 0x08, 0x07, 0x03, 0x00},
+	'(':  {0x00, 0x1C, 0x22, 0x41, 0x00},
+	')':  {0x00, 0x41, 0x22, 0x1C, 0x00},
+	'*':  {0x2A, 0x1C, 0x7F, 0x1C, 0x2A},
+	'+':  {0x08, 0x08, 0x3E, 0x08, 0x08},
+	',':  {0x00, 0x80, 0x70, 0x30, 0x00},
+	'-':  {0x08, 0x08, 0x08, 0x08, 0x08},
+	'.':  {0x00, 0x00, 0x60, 0x60, 0x00},
+	'/':  {0x20, 0x10, 0x08, 0x04, 0x02},
+	'0':  {0x3E, 0x51, 0x49, 0x45, 0x3E},
+	'1':  {0x00, 0x42, 0x7F, 0x40, 0x00},
+	'2':  {0x72, 0x49, 0x49, 0x49, 0x46},
+	'3':  {0x21, 0x41, 0x49, 0x4D, 0x33},
+	'4':  {0x18, 0x14, 0x12, 0x7F, 0x10},
+	'5':  {0x27, 0x45, 0x45, 0x45, 0x39},
+	'6':  {0x3C, 0x4A, 0x49, 0x49, 0x31},
+	'7':  {0x41, 0x21, 0x11, 0x09, 0x07},
+	'8':  {0x36, 0x49, 0x49, 0x49, 0x36},
+	'9':  {0x46, 0x49, 0x49, 0x29, 0x1E},
+	':':  {0x00, 0x00, 0x14, 0x00, 0x00},
+	';':  {0x00, 0x40, 0x34, 0x00, 0x00},
+	'<':  {0x00, 0x08, 0x14, 0x22, 0x41},
+	'=':  {0x14, 0x14, 0x14, 0x14, 0x14},
+	'>':  {0x00, 0x41, 0x22, 0x14, 0x08},
+	'?':  {0x02, 0x01, 0x59, 0x09, 0x06},
+	'@':  {0x3E, 0x41, 0x5D, 0x59, 0x4E},
+	'A':  {0x7C, 0x12, 0x11, 0x12, 0x7C},
+	'B':  {0x7F, 0x49, 0x49, 0x49, 0x36},
+	'C':  {0x3E, 0x41, 0x41, 0x41, 0x22},
+	'D':  {0x7F, 0x41, 0x41, 0x41, 0x3E},
+	'E':  {0x7F, 0x49, 0x49, 0x49, 0x41},
+	'F':  {0x7F, 0x09, 0x09, 0x09, 0x01},
+	'G':  {0x3E, 0x41, 0x41, 0x51, 0x73},
+	'H':  {0x7F, 0x08, 0x08, 0x08, 0x7F},
+	'I':  {0x00, 0x41, 0x7F, 0x41, 0x00},
+	'J':  {0x20, 0x40, 0x41, 0x3F, 0x01},
+	'K':  {0x7F, 0x08, 0x14, 0x22, 0x41},
+	'L':  {0x7F, 0x40, 0x40, 0x40, 0x40},
+	'M':  {0x7F, 0x02, 0x1C, 0x02, 0x7F},
+	'N':  {0x7F, 0x04, 0x08, 0x10, 0x7F},
+	'O':  {0x3E, 0x41, 0x41, 0x41, 0x3E},
+	'P':  {0x7F, 0x09, 0x09, 0x09, 0x06},
+	'Q':  {0x3E, 0x41, 0x51, 0x21, 0x5E},
+	'R':  {0x7F, 0x09, 0x19, 0x29, 0x46},
+	'S':  {0x26, 0x49, 0x49, 0x49, 0x32},
+	'T':  {0x03, 0x01, 0x7F, 0x01, 0x03},
+	'U':  {0x3F, 0x40, 0x40, 0x40, 0x3F},
+	'V':  {0x1F, 0x20, 0x40, 0x20, 0x1F},
+	'W':  {0x3F, 0x40, 0x38, 0x40, 0x3F},
+	'X':  {0x63, 0x14, 0x08, 0x14, 0x63},
+	'Y':  {0x03, 0x04, 0x78, 0x04, 0x03},
+	'Z':  {0x61, 0x59, 0x49, 0x4D, 0x43},
+	'[':  {0x00, 0x7F, 0x41, 0x41, 0x41},
+	'\\': {0x02, 0x04, 0x08, 0x10, 0x20},
+	']':  {0x00, 0x41, 0x41, 0x41, 0x7F},
+	'^':  {0x04, 0x02, 0x01, 0x02, 0x04},
+	'_':  {0x40, 0x40, 0x40, 0x40, 0x40},
+	'`':  {0x00, 0x03, 0x07, 0x08, 0x00},
+	'a':  {0x20, 0x54, 0x54, 0x78, 0x40},
+	'b':  {0x7F, 0x28, 0x44, 0x44, 0x38},
+	'c':  {0x38, 0x44, 0x44, 0x44, 0x28},
+	'd':  {0x38, 0x44, 0x44, 0x28, 0x7F},
+	'e':  {0x38, 0x54, 0x54, 0x54, 0x18},
+	'f':  {0x00, 0x08, 0x7E, 0x09, 0x02},
+	'g':  {0x18, 0xA4, 0xA4, 0x9C, 0x78},
+	'h':  {0x7F, 0x08, 0x04, 0x04, 0x78},
+	'i':  {0x00, 0x44, 0x7D, 0x40, 0x00},
+	'j':  {0x20, 0x40, 0x40, 0x3D, 0x00},
+	'k':  {0x7F, 0x10, 0x28, 0x44, 0x00},
+	'l':  {0x00, 0x41, 0x7F, 0x40, 0x00},
+	'm':  {0x7C, 0x04, 0x78, 0x04, 0x78},
+	'n':  {0x7C, 0x08, 0x04, 0x04, 0x78},
+	'o':  {0x38, 0x44, 0x44, 0x44, 0x38},
+	'p':  {0xFC, 0x18, 0x24, 0x24, 0x18},
+	'q':  {0x18, 0x24, 0x24, 0x18, 0xFC},
+	'r':  {0x7C, 0x08, 0x04, 0x04, 0x08},
+	's':  {0x48, 0x54, 0x54, 0x54, 0x24},
+	't':  {0x04, 0x04, 0x3F, 0x44, 0x24},
+	'u':  {0x3C, 0x40, 0x40, 0x20, 0x7C},
+	'v':  {0x1C, 0x20, 0x40, 0x20, 0x1C},
+	'w':  {0x3C, 0x40, 0x30, 0x40, 0x3C},
+	'x':  {0x44, 0x28, 0x10, 0x28, 0x44},
+	'y':  {0x4C, 0x90, 0x90, 0x90, 0x7C},
+	'z':  {0x44, 0x64, 0x54, 0x4C, 0x44},
+	'{':  {0x00, 0x08, 0x36, 0x41, 0x00},
+	'|':  {0x00, 0x00, 0x77, 0x00, 0x00},
+	'}':  {0x00, 0x41, 0x36, 0x08, 0x00},
+	'~':  {0x02, 0x01, 0x02, 0x04, 0x02},
+}
+
+// Sizes of each badge style, matching their SVG templates
+var PNG_BADGE_HEIGHTS = map[string]int{"": 18, "flat": 20, "flat-square": 20, "plastic": 18, "for-the-badge": 28}
+var PNG_BADGE_RADII = map[string]int{"": 3, "flat": 3, "flat-square": 0, "plastic": 4, "for-the-badge": 0}
+
+func PNGTextWidth(text string) int {
+	length := len([]rune(text))
+	if length == 0 {
+		return 0
+	}
+	return length*PNG_GLYPH_WIDTH - 1
+}
+
+func ColorForPNG(hex string) color.RGBA {
+	r, g, b, ok := ParseHexColor(hex)
+	if !ok {
+		return color.RGBA{0x83, 0x83, 0x83, 0xFF}
+	}
+	return color.RGBA{uint8(r), uint8(g), uint8(b), 0xFF}
+}
+
+// Draws a badge in pixels, multiplying every coordinate by scale
+type BadgeRaster struct {
+	Image *image.RGBA
+	Scale int
+}
+
+func (raster BadgeRaster) FillRect(x int, width int, height int, fill color.Color) {
+	rect := image.Rect(x*raster.Scale, 0, (x+width)*raster.Scale, height*raster.Scale)
+	draw.Draw(raster.Image, rect, image.NewUniform(fill), image.Point{}, draw.Src)
+}
+
+// Unknown characters are drawn as '?'
+func (raster BadgeRaster) DrawText(text string, x int, y int, fill color.Color) {
+	pixel := image.NewUniform(fill)
+	for _, r := range text {
+		glyph, ok := PNG_FONT[r]
+		if !ok {
+			glyph = PNG_FONT['?']
+		}
+		for column, bits := range glyph {
+			for row := 0; row < 8; row++ {
+				if bits&(1<<uint(row)) != 0 {
+					left := (x + column) * raster.Scale
+					top := (y + row) * raster.Scale
+					rect := image.Rect(left, top, left+raster.Scale, top+raster.Scale)
+					draw.Draw(raster.Image, rect, pixel, image.Point{}, draw.Over)
+				}
+			}
+		}
+		x += PNG_GLYPH_WIDTH
+	}
+}
+
+// Decodes PNG, JPEG and GIF data URIs and draws them size x size with
+// nearest-neighbour scaling. SVG logos can't be rasterized, and logos larger
+// than MAX_PNG_LOGO_SIZE aren't decoded, so both are skipped.
+func (raster BadgeRaster) DrawLogo(data_uri string, x int, y int, size int) {
+	parts := strings.SplitN(data_uri, ",", 2)
+	if len(parts) != 2 || strings.HasPrefix(parts[0], "data:image/svg") {
+		return
+	}
+	logo_bytes, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return
+	}
+	// The header is enough to tell the size before allocating the image
+	config, _, err := image.DecodeConfig(bytes.NewReader(logo_bytes))
+	if err != nil || config.Width > MAX_PNG_LOGO_SIZE || config.Height > MAX_PNG_LOGO_SIZE {
+		return
+	}
+	logo, _, err := image.Decode(bytes.NewReader(logo_bytes))
+	if err != nil {
+		return
+	}
+	bounds := logo.Bounds()
+	pixels := size * raster.Scale
+	for row := 0; row < pixels; row++ {
+		for column := 0; column < pixels; column++ {
+			source := logo.At(bounds.Min.X+column*bounds.Dx()/pixels, bounds.Min.Y+row*bounds.Dy()/pixels)
+			rect := image.Rect(x*raster.Scale+column, y*raster.Scale+row, x*raster.Scale+column+1, y*raster.Scale+row+1)
+			draw.Draw(raster.Image, rect, image.NewUniform(source), image.Point{}, draw.Over)
+		}
+	}
+}
+
+// Makes the pixels outside each rounded corner transparent
+func (raster BadgeRaster) RoundCorners(radius int) {
+	r := radius * raster.Scale
+	bounds := raster.Image.Bounds()
+	for y := 0; y < r; y++ {
+		for x := 0; x < r; x++ {
+			dx := r - x
+			dy := r - y
+			if (dx-1)*(dx-1)+(dy-1)*(dy-1) < r*r {
+				continue
+			}
+			for _, point := range []image.Point{
+				{x, y},
+				{bounds.Max.X - 1 - x, y},
+				{x, bounds.Max.Y - 1 - y},
+				{bounds.Max.X - 1 - x, bounds.Max.Y - 1 - y},
+			} {
+				raster.Image.Set(point.X, point.Y, color.Transparent)
+			}
+		}
+	}
+}
+
+// Rasterizes the same badge as GetScoreResponseAsSVG, sized for the bitmap
+// font rather than with LayoutBadge. The auto theme is drawn light.
+func GetScoreResponseAsPNG(score_svg ScoreSVG, scale int) []byte {
+	if scale < 1 || scale > MAX_PNG_SCALE {
+		scale = 1
+	}
+	score_svg = ThemeBadge(score_svg)
+	style := score_svg.Style
+	if !IsBadgeStyle(style) {
+		style = ""
+	}
+	if style == "for-the-badge" {
+		score_svg.Label = strings.ToUpper(score_svg.Label)
+		score_svg.Value = strings.ToUpper(score_svg.Value)
+	}
+
+	height := PNG_BADGE_HEIGHTS[style]
+	padding := 5
+	if style == "for-the-badge" {
+		padding = 12
+	}
+	logo_width := 0
+	if score_svg.Logo != "" {
+		logo_width = BADGE_LOGO_SIZE + BADGE_LOGO_PADDING
+	}
+
+	label_width := 0
+	if score_svg.Label != "" {
+		label_width = padding + logo_width + PNGTextWidth(score_svg.Label) + padding
+	} else if logo_width > 0 {
+		label_width = padding + BADGE_LOGO_SIZE + padding
+	}
+	value_width := padding + PNGTextWidth(score_svg.Value) + padding
+	label_x, value_x := 0, label_width
+	if style == "" {
+		if value_width < 25 {
+			value_width = 25
+		}
+		label_x, value_x = value_width, 0
+	}
+
+	raster := BadgeRaster{
+		Image: image.NewRGBA(image.Rect(0, 0, (label_width+value_width)*scale, height*scale)),
+		Scale: scale,
+	}
+	raster.FillRect(label_x, label_width, height, ColorForPNG(score_svg.LabelColor))
+	raster.FillRect(value_x, value_width, height, ColorForPNG(score_svg.Color))
+
+	text_y := (height - 7) / 2
+	shadow := color.RGBA{0x01, 0x01, 0x01, 0x4D}
+	label_text := ColorForPNG(score_svg.LabelTextColor)
+	value_text := ColorForPNG(score_svg.ValueTextColor)
+	if logo_width > 0 {
+		raster.DrawLogo(score_svg.Logo, label_x+padding, (height-BADGE_LOGO_SIZE)/2, BADGE_LOGO_SIZE)
+	}
+	label_text_x := label_x + padding + logo_width
+	value_text_x := value_x + (value_width-PNGTextWidth(score_svg.Value))/2
+	if style == "flat" || style == "plastic" {
+		raster.DrawText(score_svg.Label, label_text_x, text_y+1, shadow)
+		raster.DrawText(score_svg.Value, value_text_x, text_y+1, shadow)
+	}
+	raster.DrawText(score_svg.Label, label_text_x, text_y, label_text)
+	raster.DrawText(score_svg.Value, value_text_x, text_y, value_text)
+	raster.RoundCorners(PNG_BADGE_RADII[style])
+
+	var doc bytes.Buffer
+	err := png.Encode(&doc, raster.Image)
+	HandleError(err)
+
+	return doc.Bytes()
+}
+
+func GetScoreErrorAsPNG(options ScoreOptions) []byte {
+	return GetScoreResponseAsPNG(options.ErrorTemplate(), options.Scale)
+}
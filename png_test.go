@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"runtime"
+	"testing"
+)
+
+func LogoDataURI(t *testing.T, logo image.Image) string {
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, logo); err != nil {
+		t.Fatal(err)
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(encoded.Bytes())
+}
+
+func TestDrawLogo(t *testing.T) {
+	logo := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for x := 0; x < 8; x++ {
+		for y := 0; y < 8; y++ {
+			logo.Set(x, y, color.RGBA{255, 0, 0, 255})
+		}
+	}
+	raster := BadgeRaster{Image: image.NewRGBA(image.Rect(0, 0, 40, 40)), Scale: 2}
+	raster.DrawLogo(LogoDataURI(t, logo), 2, 2, BADGE_LOGO_SIZE)
+
+	if drawn := raster.Image.RGBAAt(4, 4); drawn != (color.RGBA{255, 0, 0, 255}) {
+		t.Errorf("Logo wasn't drawn, got %v", drawn)
+	}
+}
+
+// A header claiming a huge image would make image.Decode allocate all of it
+func TestDrawLogoSkipsOversizedLogos(t *testing.T) {
+	var encoded bytes.Buffer
+	png.Encode(&encoded, image.NewNRGBA64(image.Rect(0, 0, 1, 1)))
+	logo_bytes := encoded.Bytes()
+	// IHDR's width and height follow the 8 byte signature and chunk header,
+	// and its checksum covers them and the chunk type
+	copy(logo_bytes[16:24], []byte{0, 0, 0x4e, 0x20, 0, 0, 0x4e, 0x20})
+	binary.BigEndian.PutUint32(logo_bytes[29:33], crc32.ChecksumIEEE(logo_bytes[12:29]))
+	data_uri := "data:image/png;base64," + base64.StdEncoding.EncodeToString(logo_bytes)
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	raster := BadgeRaster{Image: image.NewRGBA(image.Rect(0, 0, 40, 40)), Scale: 2}
+	raster.DrawLogo(data_uri, 2, 2, BADGE_LOGO_SIZE)
+	runtime.ReadMemStats(&after)
+
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Errorf("Drawing a 20000x20000 logo allocated %d bytes", allocated)
+	}
+	if drawn := raster.Image.RGBAAt(4, 4); drawn != (color.RGBA{}) {
+		t.Errorf("Oversized logo was drawn, got %v", drawn)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Buckets that have refilled are forgotten this often
+const RATE_LIMIT_PRUNE_INTERVAL = time.Minute
+
+// Health checks and metrics come from infrastructure, not API clients
+var RATE_LIMIT_EXEMPT_PATHS = []string{"/healthz", "/readyz", "/metrics"}
+
+// A token bucket per client, identified by API key or IP address. Each
+// request takes a token; tokens are added back at `Rate` a second, up to
+// `Burst`. Only the keys in `APIKeys` identify clients, so made up keys
+// can't be used to get a fresh bucket.
+type RateLimiter struct {
+	Rate              float64
+	Burst             float64
+	TrustForwardedFor bool
+	APIKeys           map[string]bool
+
+	lock      sync.Mutex
+	buckets   map[string]*TokenBucket
+	pruned_at time.Time
+}
+
+type TokenBucket struct {
+	tokens     float64
+	updated_at time.Time
+}
+
+// Nil when rate limiting is off
+func NewRateLimiter(config RateLimitConfig, api_keys []string) *RateLimiter {
+	if config.RequestsPerMinute == 0 {
+		return nil
+	}
+	known_api_keys := map[string]bool{}
+	for _, api_key := range api_keys {
+		known_api_keys[api_key] = true
+	}
+	return &RateLimiter{
+		Rate:              float64(config.RequestsPerMinute) / 60,
+		Burst:             float64(config.Burst),
+		TrustForwardedFor: config.TrustForwardedFor,
+		APIKeys:           known_api_keys,
+		buckets:           map[string]*TokenBucket{},
+		pruned_at:         time.Now(),
+	}
+}
+
+// Whether the client can make a request now, and if not how long until
+// it can
+func (limiter *RateLimiter) Allow(client string, now time.Time) (bool, time.Duration) {
+	limiter.lock.Lock()
+	defer limiter.lock.Unlock()
+	if now.Sub(limiter.pruned_at) > RATE_LIMIT_PRUNE_INTERVAL {
+		limiter.Prune(now)
+	}
+
+	bucket, ok := limiter.buckets[client]
+	if !ok {
+		bucket = &TokenBucket{tokens: limiter.Burst, updated_at: now}
+		limiter.buckets[client] = bucket
+	}
+	bucket.tokens = math.Min(limiter.Burst, bucket.tokens+now.Sub(bucket.updated_at).Seconds()*limiter.Rate)
+	bucket.updated_at = now
+	if bucket.tokens < 1 {
+		return false, time.Duration((1 - bucket.tokens) / limiter.Rate * float64(time.Second))
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+func (limiter *RateLimiter) Prune(now time.Time) {
+	for client, bucket := range limiter.buckets {
+		if bucket.tokens+now.Sub(bucket.updated_at).Seconds()*limiter.Rate >= limiter.Burst {
+			delete(limiter.buckets, client)
+		}
+	}
+	limiter.pruned_at = now
+}
+
+func (limiter *RateLimiter) ClientForRequest(req *http.Request) string {
+	if api_key := APIKeyForRequest(req); limiter.APIKeys[api_key] {
+		return "key:" + api_key
+	}
+	if limiter.TrustForwardedFor {
+		// The proxy appends the address it saw, so the last one can't be forged
+		forwarded_for := strings.Split(req.Header.Get("X-Forwarded-For"), ",")
+		if ip := strings.TrimSpace(forwarded_for[len(forwarded_for)-1]); ip != "" {
+			return "ip:" + ip
+		}
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// Responds 429 Too Many Requests, with a Retry-After header, to clients
+// over their limit
+func (limiter *RateLimiter) Middleware(next http.Handler) http.Handler {
+	if limiter == nil {
+		return next
+	}
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		for _, path := range RATE_LIMIT_EXEMPT_PATHS {
+			if strings.TrimSuffix(req.URL.Path, "/") == path {
+				next.ServeHTTP(res, req)
+				return
+			}
+		}
+		if ok, retry_after := limiter.Allow(limiter.ClientForRequest(req), time.Now()); !ok {
+			res.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retry_after.Seconds()))))
+			http.Error(res, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(res, req)
+	})
+}
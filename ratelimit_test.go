@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRateLimiterClientForRequest(t *testing.T) {
+	limiter := NewRateLimiter(RateLimitConfig{RequestsPerMinute: 60, Burst: 1}, []string{"known"})
+	tests := []struct {
+		url    string
+		client string
+	}{
+		{"/score", "ip:192.0.2.1"},
+		{"/score?api_key=known", "key:known"},
+		{"/score?api_key=made-up", "ip:192.0.2.1"},
+	}
+	for _, test := range tests {
+		if client := limiter.ClientForRequest(httptest.NewRequest("GET", test.url, nil)); client != test.client {
+			t.Errorf("%s: got client %q, expected %q", test.url, client, test.client)
+		}
+	}
+}
+
+// Each made up key would otherwise get a full bucket of its own
+func TestRateLimiterIgnoresUnknownAPIKeys(t *testing.T) {
+	limiter := NewRateLimiter(RateLimitConfig{RequestsPerMinute: 60, Burst: 1}, nil)
+	handler := limiter.Middleware(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {}))
+	for i, api_key := range []string{"a", "b"} {
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, httptest.NewRequest("GET", "/score?api_key="+api_key, nil))
+		if expected := []int{200, 429}[i]; res.Code != expected {
+			t.Errorf("Request with key %s got %d, expected %d", api_key, res.Code, expected)
+		}
+	}
+	if len(limiter.buckets) != 1 {
+		t.Errorf("Expected one bucket, got %d", len(limiter.buckets))
+	}
+}
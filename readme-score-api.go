@@ -2,48 +2,100 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"crypto/md5"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/garyburd/redigo/redis"
-	"github.com/go-martini/martini"
 	"io"
-	"io/ioutil"
-	"log"
 	"net/http"
+	"net/url"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
-	"text/template"
+	"time"
 )
 
 // Expire caches in an hour
-const CACHE_TTL = 60 * 60
+const DEFAULT_CACHE_TTL = time.Hour
+
+// How long scores are cached; see CacheConfig
+var CacheTTL = DEFAULT_CACHE_TTL
 
 type Score struct {
 	TotalScore     float32              `json:"total_score"`
 	Breakdown      map[string]float32   `json:"breakdown"`
 	HumanBreakdown map[string][]float32 `json:"human_breakdown"`
+	// How the score is shown for the request's `display` option, when it
+	// isn't the number itself. Never cached.
+	Display string `json:"-"`
 }
 
 type ScoreResponse struct {
-	Score     float32            `json:"score"`
-	URL       string             `json:"url"`
-	Breakdown map[string]float32 `json:"breakdown"`
+	Score       float32            `json:"score"`
+	Display     string             `json:"display,omitempty"`
+	URL         string             `json:"url"`
+	Breakdown   map[string]float32 `json:"breakdown"`
+	Suggestions []Suggestion       `json:"suggestions,omitempty"`
 }
 
 type HumanScoreResponse struct {
-	Score     float32              `json:"score"`
-	URL       string               `json:"url"`
-	Breakdown map[string][]float32 `json:"breakdown"`
+	Score       float32              `json:"score"`
+	Display     string               `json:"display,omitempty"`
+	URL         string               `json:"url"`
+	Breakdown   map[string][]float32 `json:"breakdown"`
+	Suggestions []Suggestion         `json:"suggestions,omitempty"`
+}
+
+// Per-request options that change how a score is rendered
+type ScoreOptions struct {
+	HumanBreakdown bool
+	Suggestions    bool
+	Style          string
+	// Badge customizations, already validated by ScoreOptionsFromQuery
+	Label      string
+	LabelColor string
+	Color      string
+	Logo       string
+	ColorScale ColorScale
+	// Pixel density of PNG badges, from 1 to MAX_PNG_SCALE
+	Scale int
+	// "grade" or "percentile" to show instead of the number
+	Display      string
+	GradeCutoffs []float32
+	// One of BADGE_THEMES, or empty for light
+	Theme string
+	// The requested URL or slug, for describing badges
+	URL string
 }
 
 type ScoreSVG struct {
-	ThreeDigitLayout  bool
-	SingleDigitLayout bool
-	Value             string
-	Color             string
+	Value      string
+	Color      string
+	Style      string
+	Label      string
+	LabelColor string
+	// A data URI, drawn before the label
+	Logo  string
+	URL   string
+	Theme string
+	// Whether LabelColor was chosen by the request, so themes keep it
+	CustomLabelColor bool
+	// Filled in by ThemeBadge
+	Title          string
+	ValueTextColor string
+	LabelTextColor string
+	ThemeStyle     string
+	// Layout filled in by LayoutBadge
+	Width      float32
+	LabelWidth float32
+	ValueWidth float32
+	LabelX     float32
+	ValueX     float32
+	LogoX      float32
 }
 
 type ErrorResponse struct {
@@ -55,71 +107,139 @@ func MarshalToJsonBytes(res interface{}) []byte {
 	return ([]byte(resAsJson))
 }
 
-func GetScoreResponseAsJson(score Score, url_or_slug string, human_breakdown bool) []byte {
+// Invalid colors and logos are ignored rather than failing the request, so
+// a badge is always drawn.
+func ScoreOptionsFromQuery(query_params url.Values) ScoreOptions {
+	options := ScoreOptions{
+		HumanBreakdown: query_params.Get("human_breakdown") == "true",
+		Suggestions:    query_params.Get("suggestions") == "true",
+		Style:          query_params.Get("style"),
+		Label:          BADGE_LABEL,
+		ColorScale:     ColorScaleFromQuery(query_params),
+		Scale:          1,
+		Display:        query_params.Get("display"),
+		GradeCutoffs:   DefaultGradeCutoffs,
+		Theme:          ValidBadgeTheme(query_params.Get("theme")),
+		URL:            strings.ToLower(query_params.Get("url")),
+	}
+	if options.URL == "" {
+		options.URL = strings.ToLower(query_params.Get("github"))
+	}
+	if cutoffs, err := ParseGradeCutoffs(query_params.Get("grades")); err == nil {
+		options.GradeCutoffs = cutoffs
+	}
+	if scale, err := strconv.Atoi(query_params.Get("scale")); err == nil && scale >= 1 && scale <= MAX_PNG_SCALE {
+		options.Scale = scale
+	}
+	if labels, ok := query_params["label"]; ok {
+		options.Label = ValidBadgeLabel(labels[0])
+	}
+	options.LabelColor, _ = ValidBadgeColor(query_params.Get("labelColor"))
+	options.Color, _ = ValidBadgeColor(query_params.Get("color"))
+	options.Logo, _ = ValidBadgeLogo(query_params.Get("logo"))
+	return options
+}
+
+func (options ScoreOptions) BadgeLabelColor() string {
+	if options.LabelColor != "" {
+		return options.LabelColor
+	}
+	if IsBadgeStyle(options.Style) {
+		return BADGE_STYLE_LABEL_COLOR
+	}
+	return BADGE_LABEL_COLOR
+}
+
+func GetScoreResponseAsJson(score Score, url_or_slug string, options ScoreOptions) []byte {
 	var res interface{}
+	var suggestions []Suggestion
+	if options.Suggestions {
+		suggestions = score.Suggestions()
+	}
 
-	if human_breakdown {
+	if options.HumanBreakdown {
 		res = &HumanScoreResponse{
-			Score:     score.TotalScore,
-			Breakdown: score.HumanBreakdown,
-			URL:       url_or_slug}
+			Score:       score.TotalScore,
+			Display:     score.Display,
+			Breakdown:   score.HumanBreakdown,
+			URL:         url_or_slug,
+			Suggestions: suggestions}
 	} else {
 		res = &ScoreResponse{
-			Score:     score.TotalScore,
-			Breakdown: score.Breakdown,
-			URL:       url_or_slug}
+			Score:       score.TotalScore,
+			Display:     score.Display,
+			Breakdown:   score.Breakdown,
+			URL:         url_or_slug,
+			Suggestions: suggestions}
 	}
 
 	return MarshalToJsonBytes(res)
 }
 
-func (score Score) AsColor() string {
-	if score.TotalScore < 25 {
-		return "#E74C3C"
+// The score as shown to people: its Display, or the whole number
+func (score Score) DisplayValue() string {
+	if score.Display != "" {
+		return score.Display
 	}
-	if score.TotalScore < 80 {
-		return "#F39C12"
-	}
-	return "#2ECC71"
+	return strconv.Itoa(int(score.TotalScore))
+}
+
+func (score Score) AsColor() string {
+	return DefaultColorScale.ColorFor(score.TotalScore)
+}
+
+func (score Score) AsColorWithScale(scale ColorScale) string {
+	return scale.ColorFor(score.TotalScore)
 }
 
-func (score Score) AsScoreTemplate() ScoreSVG {
+func (score Score) AsScoreTemplate(options ScoreOptions) ScoreSVG {
+	color := score.AsColorWithScale(options.ColorScale)
+	if options.Color != "" {
+		color = options.Color
+	}
 	return ScoreSVG{
-		ThreeDigitLayout:  score.TotalScore >= 100,
-		SingleDigitLayout: score.TotalScore < 10,
-		Value:             strconv.Itoa(int(score.TotalScore)),
-		Color:             score.AsColor(),
+		Value:            score.DisplayValue(),
+		Color:            color,
+		Style:            options.Style,
+		Label:            options.Label,
+		LabelColor:       options.BadgeLabelColor(),
+		Logo:             options.Logo,
+		URL:              options.URL,
+		Theme:            options.Theme,
+		CustomLabelColor: options.LabelColor != "",
 	}
 }
 
-var score_template_string = ""
-var score_template = template.New("score template")
-
+// Renders templates/score.svg, or the badge template for score_svg.Style if
+// it's one of BADGE_STYLES.
 func GetScoreResponseAsSVG(score_svg ScoreSVG) []byte {
-	var doc bytes.Buffer
-	var err error
-
-	if score_template_string == "" {
-		var score_template_bytes []byte
-		if score_template_bytes, err = ioutil.ReadFile("./templates/score.svg"); err == nil {
-			score_template_string = string(score_template_bytes)
-		}
-		score_template, err = score_template.Parse(score_template_string)
+	score_svg = ThemeBadge(LayoutBadge(score_svg))
+	if IsBadgeStyle(score_svg.Style) {
+		return GetBadgeAsSVG(score_svg)
 	}
 
-	if err == nil {
-		err = score_template.Execute(&doc, score_svg)
-	}
+	doc, err := ExecuteTemplate("score.svg", score_svg)
 	HandleError(err)
 
-	return doc.Bytes()
+	return doc
+}
+
+func (options ScoreOptions) ErrorTemplate() ScoreSVG {
+	return ScoreSVG{
+		Value:            "Err",
+		Color:            "#838383",
+		Style:            options.Style,
+		Label:            options.Label,
+		LabelColor:       options.BadgeLabelColor(),
+		Logo:             options.Logo,
+		URL:              options.URL,
+		Theme:            options.Theme,
+		CustomLabelColor: options.LabelColor != "",
+	}
 }
 
-func GetScoreErrorAsSVG() []byte {
-	return GetScoreResponseAsSVG(ScoreSVG{
-		Value: "Err",
-		Color: "#838383",
-	})
+func GetScoreErrorAsSVG(options ScoreOptions) []byte {
+	return GetScoreResponseAsSVG(options.ErrorTemplate())
 }
 
 func GetScoreErrorAsJson(url_or_slug string) []byte {
@@ -132,7 +252,7 @@ func CacheKeyForUrlOrSlug(url_or_slug string) string {
 	return "url_or_slug_v4:" + url_or_slug
 }
 
-func WriteSVGWithETag(res http.ResponseWriter, body []byte) {
+func WriteWithETag(res http.ResponseWriter, body []byte) {
 	hash := md5.New()
 	io.WriteString(hash, string(body))
 	etag := fmt.Sprintf("\"%x\"", hash.Sum(nil))
@@ -140,21 +260,80 @@ func WriteSVGWithETag(res http.ResponseWriter, body []byte) {
 	res.Write(body)
 }
 
-func (server *Server) GetScore(res http.ResponseWriter, req *http.Request, params martini.Params) {
-	query_params := req.URL.Query()
-	url_or_slug := ""
-	ok := false
-	human_breakdown := false
-	force := false
-	format := params["format"]
+func WriteSVGWithETag(res http.ResponseWriter, body []byte) {
+	WriteWithETag(res, body)
+}
+
+func SetContentTypeForFormat(res http.ResponseWriter, format string) {
 	if format == "svg" {
 		res.Header().Set("Content-Type", "image/svg+xml")
 		res.Header().Set("Cache-Control", "no-cache, private")
+	} else if format == "png" {
+		res.Header().Set("Content-Type", "image/png")
+		res.Header().Set("Cache-Control", "no-cache, private")
 	} else if format == "txt" {
 		res.Header().Set("Content-Type", "text/plain")
+	} else if format == "html" {
+		res.Header().Set("Content-Type", "text/html; charset=utf-8")
+	} else if format == "md" {
+		res.Header().Set("Content-Type", "text/markdown; charset=utf-8")
 	} else {
 		res.Header().Set("Content-Type", "application/json")
 	}
+}
+
+func GetScoreResponseAsText(score Score, options ScoreOptions) []byte {
+	var doc bytes.Buffer
+	doc.WriteString(score.DisplayValue())
+	if options.Suggestions {
+		for _, suggestion := range score.Suggestions() {
+			fmt.Fprintf(&doc, "\n- %s (+%s)", suggestion.Message, FormatScoreValue(suggestion.Gain))
+		}
+	}
+	return doc.Bytes()
+}
+
+func WriteScoreResponse(res http.ResponseWriter, format string, score *Score, url_or_slug string, options ScoreOptions) {
+	if score == nil {
+		if format == "svg" {
+			WriteSVGWithETag(res, GetScoreErrorAsSVG(options))
+		} else if format == "png" {
+			WriteWithETag(res, GetScoreErrorAsPNG(options))
+		} else if format == "shields" {
+			res.Write(GetScoreErrorAsShields(options))
+		} else if format == "txt" {
+			res.Write([]byte("error"))
+		} else if format == "html" {
+			res.Write(GetScoreErrorAsHTML(url_or_slug))
+		} else {
+			res.Write(GetScoreErrorAsJson(url_or_slug))
+		}
+	} else {
+		if format == "svg" {
+			WriteSVGWithETag(res, GetScoreResponseAsSVG(score.AsScoreTemplate(options)))
+		} else if format == "png" {
+			WriteWithETag(res, GetScoreResponseAsPNG(score.AsScoreTemplate(options), options.Scale))
+		} else if format == "shields" {
+			res.Write(GetScoreResponseAsShields(*score, options))
+		} else if format == "txt" {
+			res.Write(GetScoreResponseAsText(*score, options))
+		} else if format == "html" {
+			res.Write(GetScoreResponseAsHTML(*score, url_or_slug, options))
+		} else {
+			res.Write(GetScoreResponseAsJson(*score, url_or_slug, options))
+		}
+	}
+}
+
+func (server *Server) GetScore(res http.ResponseWriter, req *http.Request, params Params) {
+	query_params := req.URL.Query()
+	url_or_slug := ""
+	ok := false
+	options := ScoreOptionsFromQuery(query_params)
+	force := false
+	sha := query_params.Get("sha")
+	format := params["format"]
+	SetContentTypeForFormat(res, format)
 	var param_matches []string
 	var score *Score
 	var err error
@@ -169,72 +348,152 @@ func (server *Server) GetScore(res http.ResponseWriter, req *http.Request, param
 	if err == nil {
 		url_or_slug = strings.ToLower(param_matches[0])
 
-		if param_matches, ok = query_params["human_breakdown"]; ok {
-			human_breakdown = param_matches[0] == "true"
-		}
-
 		if param_matches, ok = query_params["force"]; ok {
 			force = true
 		}
 
-		score, err = server.GetScoreForUrlOrSlug(url_or_slug, force)
+		score, err = server.GetScoreForUrlOrSlug(req.Context(), url_or_slug, force, sha)
 
 	}
 	HandleError(err)
+	server.ApplyDisplay(score, options)
 
-	if score == nil {
-		if format == "svg" {
-			WriteSVGWithETag(res, GetScoreErrorAsSVG())
-		} else if format == "txt" {
-			res.Write([]byte("error"))
-		} else {
-			res.Write(GetScoreErrorAsJson(url_or_slug))
-		}
-	} else {
-		if format == "svg" {
-			WriteSVGWithETag(res, GetScoreResponseAsSVG(score.AsScoreTemplate()))
-		} else if format == "txt" {
-			res.Write([]byte(strconv.Itoa(int(score.TotalScore))))
-		} else {
-			res.Write(GetScoreResponseAsJson(*score, url_or_slug, human_breakdown))
-		}
-	}
-
+	WriteScoreResponse(res, format, score, url_or_slug, options)
 }
 
-func (server *Server) GetCachedScoreForUrlOrSlug(url_or_slug string) (*Score, error) {
+func (server *Server) GetCachedScore(ctx context.Context, cache_key string) (*Score, error) {
+	_, span := StartSpan(ctx, "cache get", SPAN_KIND_CLIENT)
+	defer span.Finish()
+	span.SetAttribute("db.system", "redis")
+	span.SetAttribute("db.operation", "GET")
+	span.SetAttribute("cache.key", cache_key)
 	var score *Score
-	scoreJson, err := redis.String(server.Redis("GET", CacheKeyForUrlOrSlug(url_or_slug)))
+	scoreJson, err := redis.String(server.Redis("GET", cache_key))
 	if scoreJson != "" {
 		score = &Score{}
 		if err = json.Unmarshal([]byte(scoreJson), &score); err != nil {
 			score = nil
 		}
 	}
+	span.SetAttribute("cache.hit", score != nil)
+	if err != redis.ErrNil {
+		span.RecordError(err)
+	}
 
 	return score, err
 }
 
-func (server *Server) CacheScoreForUrlOrSlug(scoreJson string, url_or_slug string) {
-	server.Redis("SET", CacheKeyForUrlOrSlug(url_or_slug), scoreJson)
-	server.Redis("EXPIRE", CacheKeyForUrlOrSlug(url_or_slug), CACHE_TTL)
+func (server *Server) CacheScore(ctx context.Context, scoreJson string, cache_key string) {
+	_, span := StartSpan(ctx, "cache set", SPAN_KIND_CLIENT)
+	defer span.Finish()
+	span.SetAttribute("db.system", "redis")
+	span.SetAttribute("db.operation", "SET")
+	span.SetAttribute("cache.key", cache_key)
+	_, err := server.Redis("SET", cache_key, scoreJson)
+	span.RecordError(err)
+	server.Redis("EXPIRE", cache_key, int(CacheTTL.Seconds()))
+}
+
+func (server *Server) GetCachedScoreForUrlOrSlug(ctx context.Context, url_or_slug string) (*Score, error) {
+	return server.GetCachedScore(ctx, CacheKeyForUrlOrSlug(url_or_slug))
 }
 
-func (server *Server) GetScoreForUrlOrSlug(url_or_slug string, force bool) (*Score, error) {
+func (server *Server) CacheScoreForUrlOrSlug(ctx context.Context, scoreJson string, url_or_slug string) {
+	server.CacheScore(ctx, scoreJson, CacheKeyForUrlOrSlug(url_or_slug))
+}
+
+// Path to the Ruby scorer, relative to the working directory by default
+const DEFAULT_SCORER_PATH = "./get_score.rb"
+
+var ScorerPath = DEFAULT_SCORER_PATH
+
+// Where the scorer's stderr goes outside of requests; the CLI shows it so
+// Ruby and Bundler errors aren't lost
+var ScorerStderr io.Writer
+
+// Runs the Ruby scorer and returns the score JSON it prints on its last line.
+// stdin may be nil when the scorer doesn't need any input. During a request
+// the scorer gets its id as REQUEST_ID, and its stderr is logged with it.
+// When tracing, the scorer's span is passed on as TRACEPARENT, and the spans
+// it reports back are recorded under it.
+func RunScorer(ctx context.Context, stdin io.Reader, args ...string) (scoreJson string, err error) {
+	started := time.Now()
+	finish := Metrics.StartScoring()
+	defer func() { finish(err) }()
+	ctx, span := StartSpan(ctx, "scorer", SPAN_KIND_INTERNAL)
+	defer func() {
+		span.RecordError(err)
+		span.Finish()
+	}()
+	span.SetAttribute("process.command", ScorerPath)
+	span.SetAttribute("process.command_args", strings.Join(args, " "))
+	// Run from the scorer's directory with its Gemfile, wherever we were
+	// started, so Bundler loads the scorer's gems and not the caller's
+	scorer_path, err := filepath.Abs(ScorerPath)
+	if err != nil {
+		return "", err
+	}
+	var scoreOut bytes.Buffer
+	rubyCmd := exec.Command(scorer_path, args...)
+	rubyCmd.Dir = filepath.Dir(scorer_path)
+	rubyCmd.Stdin = stdin
+	rubyCmd.Stdout = &scoreOut
+	rubyCmd.Stderr = ScorerStderr
+	var env []string
+	if os.Getenv("BUNDLE_GEMFILE") == "" {
+		env = append(env, "BUNDLE_GEMFILE="+filepath.Join(rubyCmd.Dir, "Gemfile"))
+	}
+	if request_id := RequestIDFromContext(ctx); request_id != "" {
+		stderr := &ScorerLogWriter{ctx: ctx}
+		defer stderr.Flush()
+		rubyCmd.Stderr = stderr
+		env = append(env, "REQUEST_ID="+request_id)
+	}
+	if traceparent := TraceparentFromContext(ctx); traceparent != "" {
+		env = append(env, "TRACEPARENT="+traceparent)
+	}
+	if env != nil {
+		rubyCmd.Env = append(os.Environ(), env...)
+	}
+	if err = ScorerJobs.Start(rubyCmd); err != nil {
+		return "", err
+	}
+	err = rubyCmd.Wait()
+	ScorerJobs.Finish(rubyCmd)
+	RequestLogFromContext(ctx).AddScorerDuration(time.Since(started))
+	RecordScorerSpans(ctx, scoreOut.String())
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(scoreOut.String(), "\n")
+	if len(lines) < 2 {
+		return "", ScorerOutputError("Scorer produced no output")
+	}
+	return lines[len(lines)-2], nil
+}
+
+func ParseScoreJson(scoreJson string) (*Score, error) {
+	score := &Score{}
+	if err := json.Unmarshal([]byte(scoreJson), &score); err != nil {
+		return nil, err
+	}
+	return score, nil
+}
+
+// Scores computed here (rather than read from the cache) are added to the
+// URL or slug's history along with the commit SHA, if the caller knows it.
+func (server *Server) GetScoreForUrlOrSlug(ctx context.Context, url_or_slug string, force bool, sha string) (*Score, error) {
 	var score *Score
 	var err error
-	if score, err = server.GetCachedScoreForUrlOrSlug(url_or_slug); err != nil || force {
-		log.Printf("Cache miss for %s (forced? %t)", url_or_slug, force)
-		log.Print(err)
-		rubyCmd := exec.Command("./get_score.rb", url_or_slug)
-		var scoreOut []byte
-		if scoreOut, err = rubyCmd.Output(); err == nil {
-			lines := strings.Split(string(scoreOut), "\n")
-			scoreJson := lines[len(lines)-2]
-			server.CacheScoreForUrlOrSlug(scoreJson, url_or_slug)
-			score = &Score{}
-			if err = json.Unmarshal([]byte(scoreJson), &score); err != nil {
-				score = nil
+	score, err = server.GetCachedScoreForUrlOrSlug(ctx, url_or_slug)
+	RequestLogFromContext(ctx).AddScore(url_or_slug, Metrics.RecordCacheLookup(score, force))
+	if err != nil || force {
+		var scoreJson string
+		if scoreJson, err = RunScorer(ctx, nil, url_or_slug); err == nil {
+			server.CacheScoreForUrlOrSlug(ctx, scoreJson, url_or_slug)
+			if score, err = ParseScoreJson(scoreJson); err == nil {
+				server.RecordScoreHistory(url_or_slug, score, sha)
+				server.RecordScoreSeen(url_or_slug, score)
 			}
 		}
 	}
@@ -243,7 +502,10 @@ func (server *Server) GetScoreForUrlOrSlug(url_or_slug string, force bool) (*Sco
 }
 
 func main() {
-	server := &Server{}
-	fmt.Printf("%p\n", &(*server))
+	if IsCLIInvocation(os.Args) {
+		os.Exit(RunCLI(os.Args[1:]))
+	}
+
+	server := &Server{Config: ConfigFromCommandLine()}
 	server.Start()
 }
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+)
+
+// Values captured by a route's named groups, like "format" in
+// /score(\.(?P<format>json|svg))?
+type Params map[string]string
+
+type HandlerFunc func(res http.ResponseWriter, req *http.Request, params Params)
+
+// For handlers that don't use any params
+func WithoutParams(handler http.HandlerFunc) HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request, params Params) {
+		handler(res, req)
+	}
+}
+
+type Route struct {
+	Method  string
+	Pattern *regexp.Regexp
+	Handler HandlerFunc
+}
+
+// Routes are tried in the order they're added. A pattern is a regular
+// expression that has to match the whole path, with an optional trailing
+// slash, and GET routes also answer HEAD requests. Unmatched requests get
+// http.NotFound.
+type Router struct {
+	routes []Route
+}
+
+func (router *Router) Handle(method string, pattern string, handler HandlerFunc) {
+	router.routes = append(router.routes, Route{
+		Method:  method,
+		Pattern: regexp.MustCompile(`^(?:` + pattern + `)\/?$`),
+		Handler: handler,
+	})
+}
+
+func (router *Router) Get(pattern string, handler HandlerFunc) {
+	router.Handle("GET", pattern, handler)
+}
+
+func (router *Router) Post(pattern string, handler HandlerFunc) {
+	router.Handle("POST", pattern, handler)
+}
+
+func (route Route) Match(method string, path string) (Params, bool) {
+	if method != route.Method && !(method == "HEAD" && route.Method == "GET") {
+		return nil, false
+	}
+	matches := route.Pattern.FindStringSubmatch(path)
+	if matches == nil {
+		return nil, false
+	}
+	params := Params{}
+	for i, name := range route.Pattern.SubexpNames() {
+		if name != "" {
+			params[name] = matches[i]
+		}
+	}
+	return params, true
+}
+
+func (router *Router) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	for _, route := range router.routes {
+		if params, ok := route.Match(req.Method, req.URL.Path); ok {
+			route.Handler(res, req, params)
+			return
+		}
+	}
+	http.NotFound(res, req)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestRouteMatch(t *testing.T) {
+	router := &Router{}
+	router.Get("/score(\\.(?P<format>json|svg))?", nil)
+	router.Post("/a|/b", nil)
+	score, either := router.routes[0], router.routes[1]
+
+	tests := []struct {
+		route  Route
+		method string
+		path   string
+		ok     bool
+		format string
+	}{
+		{score, "GET", "/score", true, ""},
+		{score, "GET", "/score.json", true, "json"},
+		{score, "GET", "/score.svg/", true, "svg"},
+		{score, "HEAD", "/score.svg", true, "svg"},
+		{score, "POST", "/score", false, ""},
+		{score, "GET", "/score.gif", false, ""},
+		{score, "GET", "/scores", false, ""},
+		{score, "GET", "/api/score", false, ""},
+		{score, "GET", "/score//", false, ""},
+		{either, "POST", "/a", true, ""},
+		{either, "POST", "/b/", true, ""},
+		{either, "POST", "/a/b", false, ""},
+		{either, "POST", "/x/b", false, ""},
+	}
+	for _, test := range tests {
+		params, ok := test.route.Match(test.method, test.path)
+		if ok != test.ok {
+			t.Errorf("%s %s: matched %t, expected %t", test.method, test.path, ok, test.ok)
+		}
+		if ok && params["format"] != test.format {
+			t.Errorf("%s %s: got format %q, expected %q", test.method, test.path, params["format"], test.format)
+		}
+	}
+}
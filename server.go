@@ -1,72 +1,176 @@
 package main
 
 import (
-	"fmt"
+	"context"
 	"github.com/garyburd/redigo/redis"
-	"github.com/go-martini/martini"
-	"github.com/martini-contrib/cors"
-	"github.com/soveran/redisurl"
+	"net/http"
 	"os"
-	"time"
+	"os/signal"
+	"strconv"
+	"sync/atomic"
+	"syscall"
 )
 
+// The server environment, from server.env. Development shows panics in
+// responses and reloads templates as they change.
+const ENV_DEVELOPMENT = "development"
+const ENV_PRODUCTION = "production"
+const ENV_TEST = "test"
+
+var Env = ENV_DEVELOPMENT
+
 type Server struct {
-	Pool    *redis.Pool
-	Martini *martini.ClassicMartini
+	Config  *Config
+	Pool    RedisPool
+	Breaker *CircuitBreaker
+	Router  *Router
+	Handler http.Handler
+	History HistoryStore
 }
 
-func (server *Server) RedisAddress() string {
-	redisAddress := os.Getenv("REDIS_URL")
-	if redisAddress == "" {
-		redisAddress = os.Getenv("REDISCLOUD_URL")
-		if redisAddress == "" {
-			redisAddress = "redis://localhost:6379"
+// Connects to a single Redis server, the master found through Sentinel, or
+// a Redis Cluster, depending on the config
+func (server *Server) CreatePool() error {
+	config := server.Config.Redis
+	address, options, err := ParseRedisURL(config.URL)
+	if err != nil {
+		return err
+	}
+	options.ConnectTimeout = config.ConnectTimeout
+	options.ReadTimeout = config.ReadTimeout
+	options.WriteTimeout = config.WriteTimeout
+	if config.TLSCAFile != "" {
+		if options.TLS.RootCAs, err = LoadCertPool(config.TLSCAFile); err != nil {
+			return err
 		}
 	}
-	return redisAddress
+
+	switch {
+	case len(config.ClusterAddrs) > 0:
+		server.Pool = NewRedisCluster(config, options, config.ClusterAddrs)
+	case len(config.SentinelAddrs) > 0:
+		sentinel := NewRedisSentinel(config.SentinelAddrs, config.SentinelMaster, config.SentinelPassword, options)
+		server.Pool = RedisServerPool{NewRedisConnectionPool(config, func() (redis.Conn, error) {
+			return sentinel.DialMaster(options)
+		}, RedisIsMaster)}
+	default:
+		server.Pool = RedisServerPool{NewRedisConnectionPool(config, func() (redis.Conn, error) {
+			return options.Dial(address)
+		}, PingRedis)}
+	}
+	server.Breaker = &CircuitBreaker{Failures: config.BreakerFailures, Cooldown: config.BreakerCooldown}
+	return nil
 }
 
-func (server *Server) CreatePool() {
-	server.Pool = &redis.Pool{
-		MaxActive:   10,
-		MaxIdle:     3,
-		IdleTimeout: 240 * time.Second,
-		Dial: func() (redis.Conn, error) {
-			fmt.Println("Connecting to " + server.RedisAddress())
-			return redisurl.ConnectToURL(server.RedisAddress())
-		},
-		TestOnBorrow: func(c redis.Conn, t time.Time) error {
-			_, err := c.Do("PING")
-			return err
-		},
+func (server *Server) CreateHistoryStore() {
+	server.History = &RedisHistoryStore{
+		Server:     server,
+		Retention:  server.Config.History.Retention,
+		MaxEntries: server.Config.History.MaxEntries,
 	}
 }
 
+// Fails fast with ErrRedisUnavailable while the circuit breaker is open
 func (server *Server) Redis(commandName string, args ...interface{}) (reply interface{}, err error) {
-	conn := server.Pool.Get()
-	defer conn.Close()
-	return conn.Do(commandName, args...)
+	if !server.Breaker.Allow() {
+		return nil, ErrRedisUnavailable
+	}
+	atomic.AddInt64(&Metrics.RedisInUse, 1)
+	defer atomic.AddInt64(&Metrics.RedisInUse, -1)
+	reply, err = server.Pool.Do(commandName, args...)
+	server.Breaker.Record(err)
+	return reply, err
+}
+
+func (server *Server) CreateRouter() {
+	server.Router = &Router{}
+	server.Router.Get("/score(\\.(?P<format>json|html|svg|png|txt|shields))?", server.GetScore)
+	server.Router.Post("/score(\\.(?P<format>json|html|svg|png|txt|shields))?", server.PostScore)
+	server.Router.Get("/breakdown.svg", server.GetBreakdown)
+	server.Router.Get("/compare(\\.(?P<format>json|txt|md))?", server.GetCompare)
+	server.Router.Get("/history(\\.(?P<format>json|svg|txt))?", server.GetHistory)
+	server.Router.Get("/metrics", WithoutParams(server.GetMetrics))
+	server.Router.Get("/healthz", WithoutParams(server.GetHealthz))
+	server.Router.Get("/readyz", WithoutParams(server.GetReadyz))
 }
 
-func (server *Server) CreateMartini() {
-	fmt.Println(&server)
-	server.Martini = martini.Classic()
-	server.Martini.Use(cors.Allow(&cors.Options{
-		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"GET"},
-		ExposeHeaders:    []string{"Content-Type, Cache-Control, Expires, Etag, Last-Modified"},
-		AllowCredentials: true,
-	}))
-	server.Martini.Get("/score(\\.(?P<format>json|html|svg|txt))?", server.GetScore)
+// Wraps the router in the middleware every request goes through. Requests
+// are counted, logged and traced outside of Recover so panics are recorded
+// as 500s.
+func (server *Server) CreateHandler() error {
+	cors_policy, err := NewCORSPolicy(server.Config.CORS)
+	if err != nil {
+		return err
+	}
+	server.CreateRouter()
+	server.Handler = Chain(server.Router,
+		Metrics.CountRequests,
+		LogRequests,
+		TraceRequests,
+		Recover,
+		ServeStatic("public"),
+		cors_policy.Middleware,
+		server.RequireClientCert,
+		NewRateLimiter(server.Config.RateLimit, cors_policy.APIKeys()).Middleware,
+	)
+	return nil
 }
 
+// Parses the templates once before serving. The templates directory can hold
+// replacements for any of them, which are reloaded as they change in
+// development.
+func (server *Server) LoadTemplates() {
+	templates_dir := server.Config.Server.TemplatesDir
+	HandleError(LoadTemplates(templates_dir))
+	if templates_dir != "" && Env == ENV_DEVELOPMENT {
+		go WatchTemplates(templates_dir, nil)
+	}
+}
+
+// Listens on the configured host and port, with TLS when a certificate is
+// configured, until SIGTERM or SIGINT, then shuts down gracefully.
 func (server *Server) Run() {
-	fmt.Println(&server)
-	server.Martini.Run()
+	tls_config, err := server.TLSConfig()
+	HandleError(err)
+	http_server := &http.Server{
+		Addr:         server.Config.Server.Host + ":" + strconv.Itoa(server.Config.Server.Port),
+		Handler:      server.Handler,
+		TLSConfig:    tls_config,
+		TLSNextProto: server.TLSNextProto(),
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		var err error
+		if tls_config != nil {
+			Logf(context.Background(), "listening on %s with TLS (%s)", http_server.Addr, Env)
+			err = http_server.ListenAndServeTLS("", "")
+		} else {
+			Logf(context.Background(), "listening on %s (%s)", http_server.Addr, Env)
+			err = http_server.ListenAndServe()
+		}
+		if err != http.ErrServerClosed {
+			Logf(context.Background(), "Could not listen: %s", err)
+			os.Exit(1)
+		}
+	}()
+	<-stop
+	server.Shutdown(http_server, server.Config.Server.ShutdownTimeout)
 }
 
 func (server *Server) Start() {
-	server.CreatePool()
-	server.CreateMartini()
+	if server.Config == nil {
+		server.Config = DefaultConfig()
+	}
+	server.Config.Apply()
+	server.LoadTemplates()
+	if traces_url := server.Config.OTLPTracesURL(); traces_url != "" {
+		Tracing = NewTracer(traces_url, server.Config.Tracing.ServiceName)
+		Tracing.Start()
+	}
+	HandleError(server.CreatePool())
+	server.CreateHistoryStore()
+	HandleError(server.CreateHandler())
 	server.Run()
 }
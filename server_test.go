@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update_golden = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// Compares output with testdata/<name>, or rewrites that file when the tests
+// are run with -update
+func AssertGolden(t *testing.T, name string, actual []byte) {
+	t.Helper()
+	golden_path := filepath.Join("testdata", filepath.FromSlash(name))
+	if *update_golden {
+		if err := os.MkdirAll(filepath.Dir(golden_path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(golden_path, actual, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	expected, err := ioutil.ReadFile(golden_path)
+	if err != nil {
+		t.Fatalf("%s (run the tests with -update to create it)", err)
+	}
+	if !bytes.Equal(actual, expected) {
+		t.Errorf("Output differs from %s:\n%s", golden_path, actual)
+	}
+}
+
+// Starts the API as `server.Start` would, from testdata/compat so the static
+// files come from its public directory, with a stub scorer and no Redis.
+// Stopping it returns to the original directory.
+func StartCompatServer(t *testing.T) (http_server *httptest.Server, stop func()) {
+	scorer_path, err := filepath.Abs("testdata/compat/get_score.sh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	working_dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir("testdata/compat"); err != nil {
+		t.Fatal(err)
+	}
+	previous_scorer_path := ScorerPath
+	stop = func() {
+		if http_server != nil {
+			http_server.Close()
+		}
+		ScorerPath = previous_scorer_path
+		if err := os.Chdir(working_dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	server := &Server{Config: DefaultConfig()}
+	server.Config.Server.Env = ENV_DEVELOPMENT
+	server.Config.Scorer.Path = scorer_path
+	server.Config.Redis.URL = "redis://127.0.0.1:1"
+	server.Config.Apply()
+	server.LoadTemplates()
+	if err := server.CreatePool(); err != nil {
+		stop()
+		t.Fatal(err)
+	}
+	server.CreateHistoryStore()
+	if err := server.CreateHandler(); err != nil {
+		stop()
+		t.Fatal(err)
+	}
+	http_server = httptest.NewServer(server.Handler)
+	return http_server, stop
+}
+
+func GetCompatResponse(http_server *httptest.Server, method string, path string) ([]byte, error) {
+	req, err := http.NewRequest(method, http_server.URL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	return CompatResponse(res, body), nil
+}
+
+// The status, the caching headers and the body, with the panic page's stack
+// trace left out since it changes with the code
+func CompatResponse(res *http.Response, body []byte) []byte {
+	if res.StatusCode == http.StatusInternalServerError {
+		if start, end := bytes.LastIndex(body, []byte("<pre>")), bytes.LastIndex(body, []byte("</pre>")); start >= 0 && end > start {
+			body = append(append(append([]byte{}, body[:start+len("<pre>")]...), "[stack]"...), body[end:]...)
+		}
+	}
+	header := fmt.Sprintf("Status: %d\nContent-Type: %s\nETag: %s\nCache-Control: %s\n\n",
+		res.StatusCode, res.Header.Get("Content-Type"), res.Header.Get("ETag"), res.Header.Get("Cache-Control"))
+	return append([]byte(header), body...)
+}
+
+// Responses must match those the Martini server gave, which were recorded in
+// testdata/compat/responses before it was replaced
+func TestResponsesMatchMartini(t *testing.T) {
+	http_server, stop := StartCompatServer(t)
+	tests := []struct {
+		name   string
+		method string
+		path   string
+	}{
+		{"score", "GET", "/score?url=clayallsopp/readme-score"},
+		{"score-json", "GET", "/score.json?url=clayallsopp/readme-score"},
+		{"score-svg", "GET", "/score.svg?url=clayallsopp/readme-score"},
+		{"score-txt", "GET", "/score.txt?url=clayallsopp/readme-score"},
+		{"score-html", "GET", "/score.html?url=clayallsopp/readme-score"},
+		{"score-png", "GET", "/score.png?url=clayallsopp/readme-score"},
+		{"score-svg-head", "HEAD", "/score.svg?url=clayallsopp/readme-score"},
+		{"score-json-trailing-slash", "GET", "/score.json/?url=clayallsopp/readme-score"},
+		{"breakdown-svg", "GET", "/breakdown.svg?url=clayallsopp/readme-score"},
+		{"not-found", "GET", "/nope"},
+		{"not-found-format", "GET", "/score.gif?url=clayallsopp/readme-score"},
+		{"panic", "GET", "/score.json"},
+		{"static", "GET", "/robots.txt"},
+		{"static-head", "HEAD", "/robots.txt"},
+	}
+
+	// The golden files are read once the server's stopped and we're back
+	// in the package directory
+	responses := map[string][]byte{}
+	for _, test := range tests {
+		response, err := GetCompatResponse(http_server, test.method, test.path)
+		if err != nil {
+			stop()
+			t.Fatalf("%s %s: %s", test.method, test.path, err)
+		}
+		responses[test.name] = response
+	}
+	stop()
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			AssertGolden(t, "compat/responses/"+test.name+".txt", responses[test.name])
+		})
+	}
+}
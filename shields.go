@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strings"
+)
+
+// https://shields.io/badges/endpoint-badge
+type ShieldsEndpointResponse struct {
+	SchemaVersion int    `json:"schemaVersion"`
+	Label         string `json:"label"`
+	Message       string `json:"message"`
+	Color         string `json:"color"`
+	LabelColor    string `json:"labelColor,omitempty"`
+	IsError       bool   `json:"isError"`
+	CacheSeconds  int    `json:"cacheSeconds"`
+}
+
+// shields.io takes hex colors without the #
+func ShieldsColor(color string) string {
+	return strings.TrimPrefix(color, "#")
+}
+
+func (options ScoreOptions) AsShieldsEndpoint() ShieldsEndpointResponse {
+	return ShieldsEndpointResponse{
+		SchemaVersion: 1,
+		Label:         options.Label,
+		LabelColor:    ShieldsColor(options.LabelColor),
+		CacheSeconds:  int(CacheTTL.Seconds()),
+	}
+}
+
+func GetScoreResponseAsShields(score Score, options ScoreOptions) []byte {
+	score_svg := score.AsScoreTemplate(options)
+	res := options.AsShieldsEndpoint()
+	res.Message = score.DisplayValue()
+	res.Color = ShieldsColor(score_svg.Color)
+	return MarshalToJsonBytes(&res)
+}
+
+func GetScoreErrorAsShields(options ScoreOptions) []byte {
+	res := options.AsShieldsEndpoint()
+	res.Message = "error"
+	res.Color = ShieldsColor("#838383")
+	res.IsError = true
+	return MarshalToJsonBytes(&res)
+}
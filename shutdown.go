@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// How long shutdown waits for requests and scoring jobs before killing the
+// scorers that are left. Heroku sends SIGKILL 30 seconds after SIGTERM.
+const DEFAULT_SHUTDOWN_TIMEOUT = 25 * time.Second
+
+var ErrShuttingDown = errors.New("Server is shutting down")
+
+// The scorer processes that are running, so shutdown can wait for or kill them
+type ScorerProcesses struct {
+	lock    sync.Mutex
+	running map[*exec.Cmd]bool
+	done    *sync.Cond
+	closed  bool
+}
+
+var ScorerJobs = NewScorerProcesses()
+
+func NewScorerProcesses() *ScorerProcesses {
+	processes := &ScorerProcesses{running: map[*exec.Cmd]bool{}}
+	processes.done = sync.NewCond(&processes.lock)
+	return processes
+}
+
+// Starts the command unless shutdown has begun. Call Finish once it's waited on.
+func (processes *ScorerProcesses) Start(cmd *exec.Cmd) error {
+	processes.lock.Lock()
+	defer processes.lock.Unlock()
+	if processes.closed {
+		return ErrShuttingDown
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	processes.running[cmd] = true
+	return nil
+}
+
+func (processes *ScorerProcesses) Finish(cmd *exec.Cmd) {
+	processes.lock.Lock()
+	delete(processes.running, cmd)
+	processes.lock.Unlock()
+	processes.done.Broadcast()
+}
+
+// Stops new scorers from starting
+func (processes *ScorerProcesses) Close() {
+	processes.lock.Lock()
+	processes.closed = true
+	processes.lock.Unlock()
+}
+
+// Waits for the running scorers to finish, or for the deadline. Returns
+// whether they all finished.
+func (processes *ScorerProcesses) Wait(deadline time.Time) bool {
+	timer := time.AfterFunc(time.Until(deadline), processes.done.Broadcast)
+	defer timer.Stop()
+	processes.lock.Lock()
+	defer processes.lock.Unlock()
+	for len(processes.running) > 0 && time.Now().Before(deadline) {
+		processes.done.Wait()
+	}
+	return len(processes.running) == 0
+}
+
+func (processes *ScorerProcesses) KillAll() {
+	processes.lock.Lock()
+	defer processes.lock.Unlock()
+	for cmd := range processes.running {
+		Logf(context.Background(), "Killing scorer %d (%v)", cmd.Process.Pid, cmd.Args[1:])
+		cmd.Process.Kill()
+	}
+}
+
+// Stops accepting connections, then waits for requests and scoring jobs
+// until the timeout. Scorers still running after that are killed, and the
+// Redis pool is closed last so their results can still be cached. Finally
+// the remaining spans are exported.
+func (server *Server) Shutdown(http_server *http.Server, timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	Logf(context.Background(), "Shutting down, waiting up to %s", timeout)
+	if err := http_server.Shutdown(ctx); err != nil {
+		Logf(context.Background(), "Requests still running after %s: %s", timeout, err)
+	}
+	ScorerJobs.Close()
+	if !ScorerJobs.Wait(deadline) {
+		ScorerJobs.KillAll()
+	}
+	if server.Pool != nil {
+		server.Pool.Close()
+	}
+	if Tracing != nil {
+		Tracing.Stop()
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"sort"
+)
+
+type Suggestion struct {
+	Key      string  `json:"key"`
+	Message  string  `json:"message"`
+	Gain     float32 `json:"gain"`
+	Priority int     `json:"priority"`
+}
+
+// Maps one breakdown key to advice for the README's author. Applies decides
+// whether the advice is relevant given the key's value in the breakdown.
+type SuggestionRule struct {
+	Key     string
+	Message string
+	Applies func(value float32) bool
+}
+
+func IsZero(value float32) bool {
+	return value == 0
+}
+
+func IsNegative(value float32) bool {
+	return value < 0
+}
+
+func AlwaysApplies(value float32) bool {
+	return true
+}
+
+// Rules in the order we'd rather suggest them when gains are equal
+var SUGGESTION_RULES = []SuggestionRule{
+	{"number_of_code_blocks", "Add at least one code example", IsZero},
+	{"number_of_code_blocks", "Add more code examples showing common use cases", AlwaysApplies},
+	{"low_code_block_penalty", "Expand short code snippets into complete, runnable examples", IsNegative},
+	{"cumulative_code_block_length", "Make code examples longer and more complete", AlwaysApplies},
+	{"number_of_images", "Add a screenshot or diagram", IsZero},
+	{"number_of_images", "Add more images to illustrate how it works", AlwaysApplies},
+	{"number_of_gifs", "Add an animated GIF showing it in action", IsZero},
+	{"has_lists?", "Use lists to break up long paragraphs", AlwaysApplies},
+	{"number_of_non_code_sections", "Add sections with headings, like Installation, Usage and Contributing", AlwaysApplies},
+}
+
+// The points a breakdown key could still gain, from its [points, max points]
+// human breakdown. Penalties gain back whatever they took away.
+func (score Score) PotentialGain(key string) float32 {
+	values := score.HumanBreakdown[key]
+	if len(values) == 0 {
+		return 0
+	}
+	if values[0] < 0 {
+		return -values[0]
+	}
+	if len(values) > 1 && values[1] > values[0] {
+		return values[1] - values[0]
+	}
+	return 0
+}
+
+// Suggestions for improving the score, most valuable first. Only the first
+// applicable rule for each key is used.
+func (score Score) Suggestions() []Suggestion {
+	suggestions := []Suggestion{}
+	suggested := map[string]bool{}
+	for _, rule := range SUGGESTION_RULES {
+		value, ok := score.Breakdown[rule.Key]
+		gain := score.PotentialGain(rule.Key)
+		if !ok || suggested[rule.Key] || gain <= 0 || !rule.Applies(value) {
+			continue
+		}
+		suggested[rule.Key] = true
+		suggestions = append(suggestions, Suggestion{
+			Key:     rule.Key,
+			Message: rule.Message,
+			Gain:    gain,
+		})
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].Gain > suggestions[j].Gain
+	})
+	for i := range suggestions {
+		suggestions[i].Priority = i + 1
+	}
+	return suggestions
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	html_template "html/template"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// The default templates, built into the binary so it runs from any directory
+//
+//go:embed templates
+var embedded_templates embed.FS
+
+// How often WatchTemplates checks the override directory for changes
+const TEMPLATE_WATCH_INTERVAL = time.Second
+
+// Parsed templates by their path under templates/, e.g. "badges/flat.svg".
+// .html files are parsed with html/template, everything else with
+// text/template.
+var svg_templates map[string]*template.Template
+var html_templates map[string]*html_template.Template
+var templates_lock sync.RWMutex
+
+// Parses every embedded template, preferring a file at the same path under
+// override_dir when there is one. The templates in use are only replaced if
+// all of them parse.
+func LoadTemplates(override_dir string) error {
+	new_svg_templates := map[string]*template.Template{}
+	new_html_templates := map[string]*html_template.Template{}
+
+	err := fs.WalkDir(embedded_templates, "templates", func(file_path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		name := strings.TrimPrefix(file_path, "templates/")
+		contents, err := ReadTemplate(override_dir, name)
+		if err != nil {
+			return err
+		}
+
+		if path.Ext(name) == ".html" {
+			new_html_templates[name], err = html_template.New(name).Parse(string(contents))
+		} else {
+			new_svg_templates[name], err = template.New(name).Funcs(BADGE_TEMPLATE_FUNCS).Parse(string(contents))
+		}
+		if err != nil {
+			return fmt.Errorf("Could not parse template %s: %s", name, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	templates_lock.Lock()
+	svg_templates = new_svg_templates
+	html_templates = new_html_templates
+	templates_lock.Unlock()
+	return nil
+}
+
+func ReadTemplate(override_dir string, name string) ([]byte, error) {
+	if override_dir != "" {
+		contents, err := os.ReadFile(filepath.Join(override_dir, filepath.FromSlash(name)))
+		if err == nil || !os.IsNotExist(err) {
+			return contents, err
+		}
+	}
+	return embedded_templates.ReadFile("templates/" + name)
+}
+
+// Loads the embedded templates if LoadTemplates hasn't been called yet
+func EnsureTemplates() error {
+	templates_lock.RLock()
+	loaded := svg_templates != nil
+	templates_lock.RUnlock()
+	if loaded {
+		return nil
+	}
+	return LoadTemplates("")
+}
+
+func ExecuteTemplate(name string, data interface{}) ([]byte, error) {
+	var doc bytes.Buffer
+	if err := EnsureTemplates(); err != nil {
+		return nil, err
+	}
+
+	templates_lock.RLock()
+	svg_template, is_svg := svg_templates[name]
+	html_template, is_html := html_templates[name]
+	templates_lock.RUnlock()
+
+	var err error
+	if is_svg {
+		err = svg_template.Execute(&doc, data)
+	} else if is_html {
+		err = html_template.Execute(&doc, data)
+	} else {
+		err = errors.New("No template named " + name)
+	}
+	return doc.Bytes(), err
+}
+
+// A fingerprint of every file's name, size and modification time
+func TemplateDirSignature(dir string) string {
+	var signature bytes.Buffer
+	filepath.Walk(dir, func(file_path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			fmt.Fprintf(&signature, "%s:%d:%d\n", file_path, info.Size(), info.ModTime().UnixNano())
+		}
+		return nil
+	})
+	return signature.String()
+}
+
+// Reloads the templates whenever a file in dir changes, until stop is
+// closed. Meant for development; templates that fail to parse are logged
+// and the previous ones are kept.
+func WatchTemplates(dir string, stop <-chan struct{}) {
+	signature := TemplateDirSignature(dir)
+	ticker := time.NewTicker(TEMPLATE_WATCH_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			new_signature := TemplateDirSignature(dir)
+			if new_signature == signature {
+				continue
+			}
+			signature = new_signature
+			if err := LoadTemplates(dir); err != nil {
+				Logf(context.Background(), "Could not reload templates from %s: %s", dir, err)
+			} else {
+				Logf(context.Background(), "Reloaded templates from %s", dir)
+			}
+		}
+	}
+}
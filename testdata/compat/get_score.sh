@@ -0,0 +1,4 @@
+#!/bin/sh
+# Stands in for get_score.rb so responses don't depend on GitHub or Ruby
+if [ "$1" = "--markdown" ]; then cat > /dev/null; fi
+echo '{"total_score":55,"human_breakdown":{"number_of_code_blocks":[15,15],"number_of_images":[0,15],"has_lists?":[10,10],"low_code_block_penalty":[0,-20],"number_of_gifs":[0,10],"cumulative_code_block_length":[0.09,10],"number_of_non_code_sections":[30,30]},"breakdown":{"number_of_code_blocks":15,"number_of_images":0,"has_lists?":10,"low_code_block_penalty":0,"number_of_gifs":0,"cumulative_code_block_length":0.09,"number_of_non_code_sections":30}}'
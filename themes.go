@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+const BADGE_TEXT_COLOR = "#FFFFFF"
+const BADGE_DARK_TEXT_COLOR = "#2C3E50"
+
+// Label background for dark pages, where the usual dark label disappears
+const BADGE_DARK_LABEL_COLOR = "#D5DBDB"
+
+// WCAG AA contrast for normal text
+const MIN_TEXT_CONTRAST = 4.5
+
+// light is the default; auto follows prefers-color-scheme
+var BADGE_THEMES = []string{"light", "dark", "auto"}
+
+func ValidBadgeTheme(theme string) string {
+	theme = strings.ToLower(theme)
+	for _, badge_theme := range BADGE_THEMES {
+		if theme == badge_theme {
+			return theme
+		}
+	}
+	return ""
+}
+
+// https://www.w3.org/TR/WCAG21/#dfn-relative-luminance
+func RelativeLuminance(color string) float64 {
+	r, g, b, ok := ParseHexColor(color)
+	if !ok {
+		return 0
+	}
+	channel := func(value float64) float64 {
+		value = value / 255
+		if value <= 0.03928 {
+			return value / 12.92
+		}
+		return math.Pow((value+0.055)/1.055, 2.4)
+	}
+	return 0.2126*channel(r) + 0.7152*channel(g) + 0.0722*channel(b)
+}
+
+func ContrastRatio(color string, other string) float64 {
+	lighter := RelativeLuminance(color)
+	darker := RelativeLuminance(other)
+	if darker > lighter {
+		lighter, darker = darker, lighter
+	}
+	return (lighter + 0.05) / (darker + 0.05)
+}
+
+// White text unless it's too faint on background, in which case whichever
+// of white and dark text contrasts more.
+func ContrastingTextColor(background string) string {
+	light_contrast := ContrastRatio(BADGE_TEXT_COLOR, background)
+	if light_contrast >= MIN_TEXT_CONTRAST || light_contrast >= ContrastRatio(BADGE_DARK_TEXT_COLOR, background) {
+		return BADGE_TEXT_COLOR
+	}
+	return BADGE_DARK_TEXT_COLOR
+}
+
+// What screen readers and tooltips say about the badge. The URL comes from
+// the query, so control characters are dropped.
+func BadgeTitle(label string, value string, url_or_slug string) string {
+	if label == "" {
+		label = "Readme score"
+	}
+	title := label + ": " + value
+	if url_or_slug = StripControlCharacters(url_or_slug); url_or_slug != "" {
+		title += " for " + url_or_slug
+	}
+	return title
+}
+
+// Fills in the badge's title and text colors, and applies its theme. Text
+// contrasts with whichever colors are used, from the color scale or custom.
+// Custom label colors are kept in every theme.
+func ThemeBadge(score_svg ScoreSVG) ScoreSVG {
+	score_svg.Title = BadgeTitle(score_svg.Label, score_svg.Value, score_svg.URL)
+	score_svg.ValueTextColor = ContrastingTextColor(score_svg.Color)
+	score_svg.LabelTextColor = ContrastingTextColor(score_svg.LabelColor)
+	if score_svg.CustomLabelColor {
+		return score_svg
+	}
+
+	dark_text_color := ContrastingTextColor(BADGE_DARK_LABEL_COLOR)
+	if score_svg.Theme == "dark" {
+		score_svg.LabelColor = BADGE_DARK_LABEL_COLOR
+		score_svg.LabelTextColor = dark_text_color
+	} else if score_svg.Theme == "auto" {
+		score_svg.ThemeStyle = fmt.Sprintf("<style>@media (prefers-color-scheme: dark) { .label { fill: %s } .label-text { fill: %s } }</style>", BADGE_DARK_LABEL_COLOR, dark_text_color)
+	}
+	return score_svg
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+// Light stops from the built-in color scales, as well as custom colors,
+// get dark text
+func TestThemeBadgeTextContrast(t *testing.T) {
+	tests := []struct {
+		color      string
+		label      bool
+		text_color string
+	}{
+		{"#F1C40F", false, BADGE_DARK_TEXT_COLOR},
+		{"#DFB317", false, BADGE_DARK_TEXT_COLOR},
+		{"#E74C3C", false, BADGE_TEXT_COLOR},
+		{"#34495E", false, BADGE_TEXT_COLOR},
+		{"#F1C40F", true, BADGE_DARK_TEXT_COLOR},
+		{BADGE_LABEL_COLOR, true, BADGE_TEXT_COLOR},
+	}
+	for _, test := range tests {
+		score_svg := ScoreSVG{Color: "#34495E", LabelColor: BADGE_LABEL_COLOR}
+		text_color := &score_svg.ValueTextColor
+		if test.label {
+			score_svg.LabelColor = test.color
+			score_svg = ThemeBadge(score_svg)
+			text_color = &score_svg.LabelTextColor
+		} else {
+			score_svg.Color = test.color
+			score_svg = ThemeBadge(score_svg)
+		}
+		if *text_color != test.text_color {
+			t.Errorf("Text on %s (label %t) is %s, expected %s", test.color, test.label, *text_color, test.text_color)
+		}
+	}
+}
+
+func TestBadgeTitleDropsControlCharacters(t *testing.T) {
+	url_or_slug := "rails/\x00rails\x1b[31m\x7f"
+	if title := BadgeTitle("", "55", url_or_slug); title != "Readme score: 55 for rails/rails[31m" {
+		t.Errorf("Got title %q", title)
+	}
+
+	for _, style := range append([]string{""}, BADGE_STYLES...) {
+		options := ScoreOptions{Style: style, URL: url_or_slug}
+		svg := GetScoreResponseAsSVG(Score{TotalScore: 55}.AsScoreTemplate(options))
+		if strings.ContainsAny(string(svg), "\x00\x1b\x7f") {
+			t.Errorf("Style %q: control characters in %s", style, svg)
+		}
+		decoder := xml.NewDecoder(strings.NewReader(string(svg)))
+		for {
+			if _, err := decoder.Token(); err != nil {
+				if err != io.EOF {
+					t.Errorf("Style %q: invalid XML: %s", style, err)
+				}
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var TLS_VERSIONS = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Serves the certificate from CertFile and KeyFile, reloading it when either
+// file changes so renewed certificates are picked up without a restart
+type CertificateReloader struct {
+	CertFile string
+	KeyFile  string
+
+	lock        sync.RWMutex
+	certificate *tls.Certificate
+	signature   string
+}
+
+func NewCertificateReloader(cert_file string, key_file string) (*CertificateReloader, error) {
+	reloader := &CertificateReloader{CertFile: cert_file, KeyFile: key_file}
+	if err := reloader.Load(); err != nil {
+		return nil, err
+	}
+	return reloader, nil
+}
+
+// Changes when either file is replaced or rewritten
+func (reloader *CertificateReloader) Signature() string {
+	signature := ""
+	for _, path := range []string{reloader.CertFile, reloader.KeyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return ""
+		}
+		signature += fmt.Sprintf("%s:%d:%d;", path, info.ModTime().UnixNano(), info.Size())
+	}
+	return signature
+}
+
+// Keeps serving the old certificate if the new one can't be loaded, e.g.
+// when only one of the files has been written so far
+func (reloader *CertificateReloader) Load() error {
+	signature := reloader.Signature()
+	certificate, err := tls.LoadX509KeyPair(reloader.CertFile, reloader.KeyFile)
+	if err != nil {
+		return err
+	}
+	reloader.lock.Lock()
+	reloader.certificate = &certificate
+	reloader.signature = signature
+	reloader.lock.Unlock()
+	return nil
+}
+
+func (reloader *CertificateReloader) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	reloader.lock.RLock()
+	defer reloader.lock.RUnlock()
+	return reloader.certificate, nil
+}
+
+func (reloader *CertificateReloader) Watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			reloader.lock.RLock()
+			unchanged := reloader.Signature() == reloader.signature
+			reloader.lock.RUnlock()
+			if unchanged {
+				continue
+			}
+			if err := reloader.Load(); err != nil {
+				Logf(context.Background(), "Could not reload certificate from %s: %s", reloader.CertFile, err)
+			} else {
+				Logf(context.Background(), "Reloaded certificate from %s", reloader.CertFile)
+			}
+		}
+	}
+}
+
+func LoadCertPool(path string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("No certificates found in " + path)
+	}
+	return pool, nil
+}
+
+// The TLS settings for serving HTTPS, or nil to serve plain HTTP. Client
+// certificates are optional for the handshake and only required for the
+// admin paths, by RequireClientCert.
+func (server *Server) TLSConfig() (*tls.Config, error) {
+	config := server.Config.TLS
+	if config.CertFile == "" {
+		return nil, nil
+	}
+	reloader, err := NewCertificateReloader(config.CertFile, config.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if config.ReloadInterval > 0 {
+		go reloader.Watch(config.ReloadInterval, nil)
+	}
+
+	tls_config := &tls.Config{
+		MinVersion:     TLS_VERSIONS[config.MinVersion],
+		GetCertificate: reloader.GetCertificate,
+		NextProtos:     []string{"http/1.1"},
+	}
+	if config.HTTP2 {
+		tls_config.NextProtos = []string{"h2", "http/1.1"}
+	}
+	if config.ClientCAFile != "" {
+		client_cas, err := LoadCertPool(config.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		tls_config.ClientCAs = client_cas
+		tls_config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tls_config, nil
+}
+
+// http.Server serves HTTP/2 over TLS unless TLSNextProto is set, so an empty
+// one turns it off when tls.http2 is false
+func (server *Server) TLSNextProto() map[string]func(*http.Server, *tls.Conn, http.Handler) {
+	if server.Config.TLS.HTTP2 {
+		return nil
+	}
+	return map[string]func(*http.Server, *tls.Conn, http.Handler){}
+}
+
+// Middleware refusing requests to the admin paths without a verified
+// client certificate, when tls.client_ca_file is set
+func (server *Server) RequireClientCert(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if server.Config.TLS.ClientCAFile != "" && server.IsAdminPath(req.URL.Path) &&
+			(req.TLS == nil || len(req.TLS.VerifiedChains) == 0) {
+			http.Error(res, "Client certificate required", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(res, req)
+	})
+}
+
+// Routes also match with a trailing slash, so /metrics/ is as much an admin
+// path as /metrics
+func (server *Server) IsAdminPath(path string) bool {
+	path = strings.TrimSuffix(path, "/")
+	for _, admin_path := range server.Config.TLS.AdminPaths {
+		if path == strings.TrimSuffix(admin_path, "/") {
+			return true
+		}
+	}
+	return false
+}
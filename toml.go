@@ -0,0 +1,225 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The subset of TOML used by config files: [tables] of `key = value` pairs,
+// where values are strings, integers, floats, booleans or arrays of those.
+// Keys before the first table are in the "" table.
+type TOMLTables map[string]map[string]interface{}
+
+func ParseTOML(document string) (TOMLTables, error) {
+	tables := TOMLTables{"": {}}
+	table := ""
+	lines := strings.Split(document, "\n")
+	for i := 0; i < len(lines); i++ {
+		line_number := i + 1
+		line := strings.TrimSpace(StripTOMLComment(lines[i]))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") || strings.HasPrefix(line, "[[") {
+				return nil, fmt.Errorf("line %d: invalid table %s", line_number, line)
+			}
+			table = strings.TrimSpace(line[1 : len(line)-1])
+			if table == "" {
+				return nil, fmt.Errorf("line %d: empty table name", line_number)
+			}
+			if _, ok := tables[table]; ok {
+				return nil, fmt.Errorf("line %d: table [%s] defined twice", line_number, table)
+			}
+			tables[table] = map[string]interface{}{}
+			continue
+		}
+
+		equals := strings.Index(line, "=")
+		if equals <= 0 {
+			return nil, fmt.Errorf("line %d: expected key = value", line_number)
+		}
+		key := strings.Trim(strings.TrimSpace(line[:equals]), `"`)
+		raw_value := strings.TrimSpace(line[equals+1:])
+		// Arrays may continue over several lines
+		for strings.HasPrefix(raw_value, "[") && !TOMLArrayClosed(raw_value) && i+1 < len(lines) {
+			i++
+			raw_value += " " + strings.TrimSpace(StripTOMLComment(lines[i]))
+		}
+		value, err := ParseTOMLValue(raw_value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", line_number, err)
+		}
+		if _, ok := tables[table][key]; ok {
+			return nil, fmt.Errorf("line %d: %s defined twice", line_number, key)
+		}
+		tables[table][key] = value
+	}
+	return tables, nil
+}
+
+// Removes a # comment, unless the # is inside a string
+func StripTOMLComment(line string) string {
+	var quote rune
+	escaped := false
+	for i, c := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case quote == '"' && c == '\\':
+			escaped = true
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+var ErrUnterminatedArray = errors.New("unterminated array")
+
+func TOMLArrayClosed(value string) bool {
+	_, _, err := ParseTOMLArray(value)
+	return err != ErrUnterminatedArray
+}
+
+func ParseTOMLValue(value string) (interface{}, error) {
+	parsed, rest, err := ParseTOMLValuePrefix(value)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(rest) != "" {
+		return nil, errors.New("unexpected " + strings.TrimSpace(rest))
+	}
+	return parsed, nil
+}
+
+// Parses the value at the start of `value` and returns what follows it
+func ParseTOMLValuePrefix(value string) (interface{}, string, error) {
+	value = strings.TrimSpace(value)
+	switch {
+	case value == "":
+		return nil, "", errors.New("missing value")
+	case value[0] == '[':
+		return ParseTOMLArray(value)
+	case value[0] == '"':
+		return ParseTOMLBasicString(value)
+	case value[0] == '\'':
+		end := strings.IndexByte(value[1:], '\'')
+		if end < 0 {
+			return nil, "", errors.New("unterminated string")
+		}
+		return value[1 : end+1], value[end+2:], nil
+	}
+
+	end := strings.IndexAny(value, ",]")
+	if end < 0 {
+		end = len(value)
+	}
+	token := strings.TrimSpace(value[:end])
+	rest := value[end:]
+	switch token {
+	case "true":
+		return true, rest, nil
+	case "false":
+		return false, rest, nil
+	}
+	number := strings.Replace(token, "_", "", -1)
+	if integer, err := strconv.ParseInt(number, 10, 64); err == nil {
+		return integer, rest, nil
+	}
+	if float, err := strconv.ParseFloat(number, 64); err == nil {
+		return float, rest, nil
+	}
+	return nil, "", errors.New("invalid value " + token)
+}
+
+func ParseTOMLBasicString(value string) (interface{}, string, error) {
+	var parsed strings.Builder
+	for i := 1; i < len(value); i++ {
+		c := value[i]
+		if c == '"' {
+			return parsed.String(), value[i+1:], nil
+		}
+		if c != '\\' {
+			parsed.WriteByte(c)
+			continue
+		}
+		if i+1 >= len(value) {
+			break
+		}
+		i++
+		switch value[i] {
+		case 'n':
+			parsed.WriteByte('\n')
+		case 't':
+			parsed.WriteByte('\t')
+		case 'r':
+			parsed.WriteByte('\r')
+		case '"', '\\':
+			parsed.WriteByte(value[i])
+		case 'u':
+			if i+4 >= len(value) {
+				return nil, "", errors.New("invalid \\u escape")
+			}
+			code, err := strconv.ParseUint(value[i+1:i+5], 16, 32)
+			if err != nil {
+				return nil, "", errors.New("invalid \\u escape")
+			}
+			parsed.WriteRune(rune(code))
+			i += 4
+		default:
+			return nil, "", fmt.Errorf("invalid escape \\%c", value[i])
+		}
+	}
+	return nil, "", errors.New("unterminated string")
+}
+
+func ParseTOMLArray(value string) (interface{}, string, error) {
+	values := []interface{}{}
+	rest := strings.TrimSpace(value[1:])
+	for {
+		if strings.HasPrefix(rest, "]") {
+			return values, rest[1:], nil
+		}
+		if rest == "" {
+			return nil, "", ErrUnterminatedArray
+		}
+		element, after, err := ParseTOMLValuePrefix(rest)
+		if err != nil {
+			return nil, "", err
+		}
+		values = append(values, element)
+		rest = strings.TrimSpace(after)
+		if strings.HasPrefix(rest, ",") {
+			rest = strings.TrimSpace(rest[1:])
+		} else if rest == "" {
+			return nil, "", ErrUnterminatedArray
+		} else if !strings.HasPrefix(rest, "]") {
+			return nil, "", errors.New("expected , or ] in array")
+		}
+	}
+}
+
+// Formats a value for printing back as TOML
+func FormatTOMLValue(value interface{}) string {
+	switch value := value.(type) {
+	case string:
+		return strconv.Quote(value)
+	case []string:
+		quoted := make([]string, len(value))
+		for i, element := range value {
+			quoted[i] = strconv.Quote(element)
+		}
+		return "[" + strings.Join(quoted, ", ") + "]"
+	}
+	return fmt.Sprint(value)
+}
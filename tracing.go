@@ -0,0 +1,465 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const TRACEPARENT_HEADER = "traceparent"
+
+// Spans are sent in batches of up to this many, or every TRACE_EXPORT_INTERVAL
+const TRACE_BATCH_SIZE = 256
+const TRACE_EXPORT_INTERVAL = 5 * time.Second
+
+// Finished spans waiting to be exported beyond this are dropped
+const MAX_QUEUED_SPANS = 4096
+
+// get_score.rb writes a line starting with this, followed by JSON, for each
+// span it reports
+const SCORER_SPAN_PREFIX = "span: "
+
+// Query parameters whose values are replaced in exported spans
+var TRACE_REDACTED_PARAMS = []string{"api_key"}
+
+// OTLP span kinds and status codes
+const (
+	SPAN_KIND_INTERNAL = 1
+	SPAN_KIND_SERVER   = 2
+	SPAN_KIND_CLIENT   = 3
+	SPAN_STATUS_ERROR  = 2
+)
+
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Sampled bool
+}
+
+// e.g. 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+func (span_context SpanContext) Traceparent() string {
+	flags := "00"
+	if span_context.Sampled {
+		flags = "01"
+	}
+	return "00-" + hex.EncodeToString(span_context.TraceID[:]) + "-" + hex.EncodeToString(span_context.SpanID[:]) + "-" + flags
+}
+
+// Parses a W3C traceparent header. Unknown versions are read as version 00,
+// as the spec asks.
+func ParseTraceparent(value string) (SpanContext, bool) {
+	var span_context SpanContext
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return span_context, false
+	}
+	trace_id, err := hex.DecodeString(parts[1])
+	if err != nil || len(trace_id) != 16 {
+		return span_context, false
+	}
+	span_id, err := hex.DecodeString(parts[2])
+	if err != nil || len(span_id) != 8 {
+		return span_context, false
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil || len(flags) != 1 {
+		return span_context, false
+	}
+	copy(span_context.TraceID[:], trace_id)
+	copy(span_context.SpanID[:], span_id)
+	span_context.Sampled = flags[0]&1 == 1
+	if span_context.TraceID == ([16]byte{}) || span_context.SpanID == ([8]byte{}) {
+		return span_context, false
+	}
+	return span_context, true
+}
+
+type Span struct {
+	Context    SpanContext
+	Parent     [8]byte
+	Name       string
+	Kind       int
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]interface{}
+	Error      string
+	tracer     *Tracer
+	lock       sync.Mutex
+}
+
+// Span methods can be called on nil, which is what StartSpan returns when
+// tracing is disabled
+func (span *Span) SetAttribute(key string, value interface{}) {
+	if span == nil {
+		return
+	}
+	span.lock.Lock()
+	span.Attributes[key] = value
+	span.lock.Unlock()
+}
+
+func (span *Span) RecordError(err error) {
+	if span == nil || err == nil {
+		return
+	}
+	span.lock.Lock()
+	span.Error = err.Error()
+	span.lock.Unlock()
+}
+
+func (span *Span) Finish() {
+	span.FinishAt(time.Now())
+}
+
+// For spans timed elsewhere, like the ones the scorer reports
+func (span *Span) FinishAt(end time.Time) {
+	if span == nil {
+		return
+	}
+	span.lock.Lock()
+	span.End = end
+	span.lock.Unlock()
+	if span.Context.Sampled {
+		span.tracer.Queue(span)
+	}
+}
+
+type Tracer struct {
+	Endpoint    string
+	ServiceName string
+	client      *http.Client
+	lock        sync.Mutex
+	queue       []*Span
+	stop        chan struct{}
+	stopped     chan struct{}
+}
+
+// nil unless an OTLP endpoint is configured; see TracingConfig
+var Tracing *Tracer
+
+// Spans are exported to the collector's traces URL with OTLP/HTTP in its
+// JSON encoding
+func NewTracer(endpoint string, service_name string) *Tracer {
+	return &Tracer{
+		Endpoint:    endpoint,
+		ServiceName: service_name,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		stop:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+}
+
+type span_key struct{}
+
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(span_key{}).(*Span)
+	return span
+}
+
+type remote_span_key struct{}
+
+// For spans started in another process, from an incoming traceparent
+func ContextWithRemoteSpan(ctx context.Context, span_context SpanContext) context.Context {
+	return context.WithValue(ctx, remote_span_key{}, span_context)
+}
+
+// The traceparent to send on to other services, or "" outside of a trace
+func TraceparentFromContext(ctx context.Context) string {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.Context.Traceparent()
+	}
+	if remote, ok := ctx.Value(remote_span_key{}).(SpanContext); ok {
+		return remote.Traceparent()
+	}
+	return ""
+}
+
+// Starts a child of the span in ctx, or of the remote span it was propagated
+// from, or a new trace. Returns a nil span when tracing is disabled.
+func StartSpan(ctx context.Context, name string, kind int) (context.Context, *Span) {
+	if Tracing == nil {
+		return ctx, nil
+	}
+	span := &Span{
+		Name:       name,
+		Kind:       kind,
+		Start:      time.Now(),
+		Attributes: map[string]interface{}{},
+		tracer:     Tracing,
+	}
+	if parent := SpanFromContext(ctx); parent != nil {
+		span.Context.TraceID = parent.Context.TraceID
+		span.Context.Sampled = parent.Context.Sampled
+		span.Parent = parent.Context.SpanID
+	} else if remote, ok := ctx.Value(remote_span_key{}).(SpanContext); ok {
+		span.Context.TraceID = remote.TraceID
+		span.Context.Sampled = remote.Sampled
+		span.Parent = remote.SpanID
+	} else {
+		rand.Read(span.Context.TraceID[:])
+		span.Context.Sampled = true
+	}
+	rand.Read(span.Context.SpanID[:])
+	return context.WithValue(ctx, span_key{}, span), span
+}
+
+func (tracer *Tracer) Queue(span *Span) {
+	tracer.lock.Lock()
+	defer tracer.lock.Unlock()
+	if len(tracer.queue) < MAX_QUEUED_SPANS {
+		tracer.queue = append(tracer.queue, span)
+	}
+}
+
+// Exports queued spans in the background until Stop
+func (tracer *Tracer) Start() {
+	go func() {
+		defer close(tracer.stopped)
+		ticker := time.NewTicker(TRACE_EXPORT_INTERVAL)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				tracer.Export()
+			case <-tracer.stop:
+				tracer.Export()
+				return
+			}
+		}
+	}()
+}
+
+// Exports the spans that are left and waits for it to finish
+func (tracer *Tracer) Stop() {
+	close(tracer.stop)
+	<-tracer.stopped
+}
+
+func (tracer *Tracer) Export() {
+	for {
+		tracer.lock.Lock()
+		batch := tracer.queue
+		if len(batch) > TRACE_BATCH_SIZE {
+			batch = batch[:TRACE_BATCH_SIZE]
+		}
+		tracer.queue = tracer.queue[len(batch):]
+		tracer.lock.Unlock()
+		if len(batch) == 0 {
+			return
+		}
+		if err := tracer.Send(batch); err != nil {
+			Logf(context.Background(), "Could not export %d spans: %s", len(batch), err)
+			return
+		}
+	}
+}
+
+func (tracer *Tracer) Send(spans []*Span) error {
+	body := MarshalToJsonBytes(tracer.AsOTLP(spans))
+	res, err := tracer.client.Post(tracer.Endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("Collector returned %d", res.StatusCode)
+	}
+	return nil
+}
+
+// The OTLP JSON encoding: ids are hex and 64-bit integers are strings
+type OTLPAttribute struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+type OTLPSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []OTLPAttribute `json:"attributes,omitempty"`
+	Status            *OTLPStatus     `json:"status,omitempty"`
+}
+
+type OTLPStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type OTLPScopeSpans struct {
+	Scope map[string]string `json:"scope"`
+	Spans []OTLPSpan        `json:"spans"`
+}
+
+type OTLPResourceSpans struct {
+	Resource   map[string][]OTLPAttribute `json:"resource"`
+	ScopeSpans []OTLPScopeSpans           `json:"scopeSpans"`
+}
+
+type OTLPTraces struct {
+	ResourceSpans []OTLPResourceSpans `json:"resourceSpans"`
+}
+
+func OTLPAttributeValue(value interface{}) map[string]interface{} {
+	switch value := value.(type) {
+	case bool:
+		return map[string]interface{}{"boolValue": value}
+	case int:
+		return map[string]interface{}{"intValue": strconv.Itoa(value)}
+	case float64:
+		return map[string]interface{}{"doubleValue": value}
+	case string:
+		return map[string]interface{}{"stringValue": value}
+	}
+	return map[string]interface{}{"stringValue": fmt.Sprint(value)}
+}
+
+func OTLPAttributes(attributes map[string]interface{}) []OTLPAttribute {
+	otlp_attributes := make([]OTLPAttribute, 0, len(attributes))
+	for key, value := range attributes {
+		otlp_attributes = append(otlp_attributes, OTLPAttribute{Key: key, Value: OTLPAttributeValue(value)})
+	}
+	return otlp_attributes
+}
+
+func (span *Span) AsOTLP() OTLPSpan {
+	span.lock.Lock()
+	defer span.lock.Unlock()
+	otlp_span := OTLPSpan{
+		TraceID:           hex.EncodeToString(span.Context.TraceID[:]),
+		SpanID:            hex.EncodeToString(span.Context.SpanID[:]),
+		Name:              span.Name,
+		Kind:              span.Kind,
+		StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+		Attributes:        OTLPAttributes(span.Attributes),
+	}
+	if span.Parent != ([8]byte{}) {
+		otlp_span.ParentSpanID = hex.EncodeToString(span.Parent[:])
+	}
+	if span.Error != "" {
+		otlp_span.Status = &OTLPStatus{Code: SPAN_STATUS_ERROR, Message: span.Error}
+	}
+	return otlp_span
+}
+
+func (tracer *Tracer) AsOTLP(spans []*Span) OTLPTraces {
+	otlp_spans := make([]OTLPSpan, len(spans))
+	for i, span := range spans {
+		otlp_spans[i] = span.AsOTLP()
+	}
+	return OTLPTraces{ResourceSpans: []OTLPResourceSpans{{
+		Resource: map[string][]OTLPAttribute{
+			"attributes": OTLPAttributes(map[string]interface{}{"service.name": tracer.ServiceName}),
+		},
+		ScopeSpans: []OTLPScopeSpans{{
+			Scope: map[string]string{"name": "readme-score-api"},
+			Spans: otlp_spans,
+		}},
+	}}}
+}
+
+// A span get_score.rb timed itself, like fetching the README for a URL or
+// slug. Times are unix seconds.
+type ScorerSpan struct {
+	Name       string                 `json:"name"`
+	Start      float64                `json:"start"`
+	End        float64                `json:"end"`
+	Error      string                 `json:"error"`
+	Attributes map[string]interface{} `json:"attributes"`
+}
+
+func UnixSecondsToTime(seconds float64) time.Time {
+	return time.Unix(0, int64(seconds*float64(time.Second)))
+}
+
+// Exports the spans reported in the scorer's output as children of the span
+// in ctx. The Ruby scorer has no exporter of its own.
+func RecordScorerSpans(ctx context.Context, output string) {
+	if Tracing == nil {
+		return
+	}
+	for _, line := range strings.Split(output, "\n") {
+		var reported ScorerSpan
+		if !strings.HasPrefix(line, SCORER_SPAN_PREFIX) || json.Unmarshal([]byte(strings.TrimPrefix(line, SCORER_SPAN_PREFIX)), &reported) != nil {
+			continue
+		}
+		_, span := StartSpan(ctx, reported.Name, SPAN_KIND_CLIENT)
+		span.Start = UnixSecondsToTime(reported.Start)
+		for key, value := range reported.Attributes {
+			span.SetAttribute(key, value)
+		}
+		if reported.Error != "" {
+			span.RecordError(errors.New(reported.Error))
+		}
+		span.FinishAt(UnixSecondsToTime(reported.End))
+	}
+}
+
+// Replaces the values of TRACE_REDACTED_PARAMS, like API keys, and leaves the
+// rest of the query as it was sent
+func RedactQuery(raw_query string) string {
+	if raw_query == "" {
+		return ""
+	}
+	pairs := strings.Split(raw_query, "&")
+	for i, pair := range pairs {
+		key := strings.SplitN(pair, "=", 2)[0]
+		name, err := url.QueryUnescape(key)
+		if err != nil {
+			name = key
+		}
+		for _, redacted := range TRACE_REDACTED_PARAMS {
+			if name == redacted {
+				pairs[i] = key + "=[redacted]"
+			}
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// Middleware starting a server span for each request, continuing the trace
+// from an incoming traceparent header
+func TraceRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if Tracing == nil {
+			next.ServeHTTP(res, req)
+			return
+		}
+		ctx := req.Context()
+		if remote, ok := ParseTraceparent(req.Header.Get(TRACEPARENT_HEADER)); ok {
+			ctx = ContextWithRemoteSpan(ctx, remote)
+		}
+		ctx, span := StartSpan(ctx, req.Method+" "+req.URL.Path, SPAN_KIND_SERVER)
+		span.SetAttribute("http.request.method", req.Method)
+		span.SetAttribute("url.path", req.URL.Path)
+		span.SetAttribute("url.query", RedactQuery(req.URL.RawQuery))
+		if request_id := RequestIDFromContext(ctx); request_id != "" {
+			span.SetAttribute("request_id", request_id)
+		}
+		defer span.Finish()
+
+		response := NewStatusWriter(res)
+		next.ServeHTTP(response, req.WithContext(ctx))
+
+		status := response.Status()
+		span.SetAttribute("http.response.status_code", status)
+		if status >= 500 {
+			span.RecordError(fmt.Errorf("%d %s", status, http.StatusText(status)))
+		}
+	})
+}